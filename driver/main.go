@@ -28,6 +28,11 @@ func main() {
 	speed := flag.Float64("speed", 20.0, "the head movement speed when extruding (in mm/sec)")
 	temp := flag.Float64("temp", 210.0, "the temperature of extrusion (in degrees celcius)")
 
+	profiles := flag.String("profiles", "", "the comma separated JSON files of printer and material profiles")
+	printerProfile := flag.String("printer", "", "the printer profile to print with")
+	materialProfile := flag.String("material", "", "the material profile to print with")
+	overrides := flag.String("set", "", "the comma separated key=value printer settings overriding the profiles")
+
 	flag.Parse()
 
 	curves := make([]*Curve, 0)
@@ -92,25 +97,33 @@ func main() {
 		log.Fatal(err)
 	}
 
-	p := printer.Printer{
-		Output:           os.Stdout,
-		Temperature:      *temp,
-		TravelSpeed:      150.0,
-		PrintSpeed:       *speed,
-		FlowCorrection:   1.0,
-		CenterX:          175.0,
-		CenterY:          100.0,
-		LayerHeight:      0.2,
-		FilamentDiameter: 2.85,
-		RetractionSpeed:  20.0,
-		RetractionLength: 2.0,
+	// the speed and temperature flags override the profiles, when given
+	assignments := []string{*overrides}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "speed":
+			assignments = append(assignments, fmt.Sprintf("print_speed=%v", *speed))
+		case "temp":
+			assignments = append(assignments, fmt.Sprintf("temperature=%v", *temp))
+		}
+	})
+	var files []string
+	if *profiles != "" {
+		files = strings.Split(*profiles, ",")
+	}
+	settings, err := printer.LoadSettings(files, *printerProfile, *materialProfile, strings.Join(assignments, ","))
+	if err != nil {
+		log.Fatal(err)
 	}
 
+	p := printer.Printer{Output: os.Stdout}
+	settings.Apply(&p)
+
 	skirtDistance := 10.0
 
 	p.Preamble()
 
-	p.Raise()
+	p.LayerChange(0, p.LayerHeight)
 
 	// print skirt
 	p.Comment("skirt")
@@ -126,6 +139,9 @@ func main() {
 	p.Print(minX-skirtDistance, minY-skirtDistance, 1.0)
 
 	for i := 0; i < 3; i++ {
+		if i > 0 {
+			p.LayerChange(i, float64(i+1)*p.LayerHeight)
+		}
 		p.Comment("layer: %d", i)
 
 		for _, curve := range curves {
@@ -134,9 +150,8 @@ func main() {
 				p.Print(point.x, point.y, *spread)
 			}
 		}
-
-		p.Raise()
 	}
 
+	p.Raise()
 	p.Postamble()
 }
@@ -0,0 +1,149 @@
+package geom
+
+import (
+	"sort"
+)
+
+// degenerateRatio is how small the height of a triangle can be, relative
+// to its longest edge, before the triangle is considered degenerate.
+const degenerateRatio = 1e-6
+
+// Report describes the defects found in a mesh. Edges are listed sorted
+// and facets by increasing index, so reports are reproducible.
+type Report struct {
+	// Edges shared by more than two triangles, and the facets using them.
+	NonManifoldEdges  []Edge
+	NonManifoldFacets []int
+
+	// Edges used by a single triangle, which border holes in the mesh,
+	// and the facets using them.
+	BoundaryEdges  []Edge
+	BoundaryFacets []int
+
+	// Edges traversed in the same direction by the two triangles sharing
+	// them, meaning one of the two is wound the wrong way, and the facets
+	// using them.
+	InconsistentEdges  []Edge
+	InconsistentFacets []int
+
+	// Facets with zero area, either because vertices coincide or because
+	// they are collinear.
+	DegenerateFacets []int
+
+	// Facets with the same vertices as a facet with a lower index,
+	// regardless of their order.
+	DuplicateFacets []int
+
+	// Facets without a stored normal, and facets whose stored normal
+	// disagrees with the order of their vertices. Slicing computes normals
+	// from the vertices, so neither makes the mesh unprintable.
+	MissingNormalFacets []int
+	WrongNormalFacets   []int
+}
+
+// Watertight returns true if the mesh is closed and every edge is shared
+// by exactly two consistently wound triangles.
+func (r *Report) Watertight() bool {
+	return len(r.NonManifoldEdges) == 0 && len(r.BoundaryEdges) == 0 && len(r.InconsistentEdges) == 0
+}
+
+// Printable returns true if no defects at all were found in the geometry.
+// Stored normals are not part of it.
+func (r *Report) Printable() bool {
+	return r.Watertight() && len(r.DegenerateFacets) == 0 && len(r.DuplicateFacets) == 0
+}
+
+// Analyze looks for the defects that make a mesh unprintable.
+func Analyze(m *Mesh) *Report {
+	r := new(Report)
+	nonManifold := make(map[int]bool)
+	boundary := make(map[int]bool)
+	inconsistent := make(map[int]bool)
+
+	for _, e := range m.Edges() {
+		triangles := m.EdgeTriangles(e)
+		switch len(triangles) {
+		case 1:
+			r.BoundaryEdges = append(r.BoundaryEdges, e)
+			boundary[triangles[0]] = true
+		case 2:
+			if m.traverses(triangles[0], e) == m.traverses(triangles[1], e) {
+				r.InconsistentEdges = append(r.InconsistentEdges, e)
+				inconsistent[triangles[0]] = true
+				inconsistent[triangles[1]] = true
+			}
+		default:
+			r.NonManifoldEdges = append(r.NonManifoldEdges, e)
+			for _, t := range triangles {
+				nonManifold[t] = true
+			}
+		}
+	}
+	r.NonManifoldFacets = sortedKeys(nonManifold)
+	r.BoundaryFacets = sortedKeys(boundary)
+	r.InconsistentFacets = sortedKeys(inconsistent)
+
+	seen := make(map[[3]int]bool)
+	for i, t := range m.Triangles {
+		if m.degenerate(i) {
+			r.DegenerateFacets = append(r.DegenerateFacets, i)
+		}
+
+		key := t
+		sort.Ints(key[:])
+		if seen[key] {
+			r.DuplicateFacets = append(r.DuplicateFacets, i)
+		}
+		seen[key] = true
+
+		switch f := m.Facet(i); {
+		case f.Normal == (Vector{}):
+			r.MissingNormalFacets = append(r.MissingNormalFacets, i)
+		case !f.NormalAgrees(NormalTolerance):
+			r.WrongNormalFacets = append(r.WrongNormalFacets, i)
+		}
+	}
+
+	return r
+}
+
+// traverses returns true if triangle i goes along e from its first to its
+// second vertex, and false if it goes the other way.
+func (m *Mesh) traverses(i int, e Edge) bool {
+	t := m.Triangles[i]
+	for j := 0; j < 3; j++ {
+		if t[j] == e[0] && t[(j+1)%3] == e[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// degenerate returns true if triangle i has no area.
+func (m *Mesh) degenerate(i int) bool {
+	t := m.Triangles[i]
+	if t[0] == t[1] || t[1] == t[2] || t[2] == t[0] {
+		return true
+	}
+
+	a, b, c := m.Vertices[t[0]], m.Vertices[t[1]], m.Vertices[t[2]]
+	longest := b.Sub(a).Length()
+	if l := c.Sub(b).Length(); l > longest {
+		longest = l
+	}
+	if l := a.Sub(c).Length(); l > longest {
+		longest = l
+	}
+
+	// twice the area is the longest edge times the height over it
+	return b.Sub(a).Cross(c.Sub(a)).Length() <= degenerateRatio*longest*longest
+}
+
+func sortedKeys(set map[int]bool) []int {
+	keys := make([]int, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,65 @@
+package geom
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeBox(t *testing.T) {
+	r := Analyze(NewMesh(box(Vector{0, 0, 0}, Vector{1, 1, 1}), 0))
+
+	if !r.Printable() {
+		t.Errorf("Expected a printable box, got %+v", r)
+	}
+}
+
+func TestAnalyzeDefects(t *testing.T) {
+	facets := box(Vector{0, 0, 0}, Vector{1, 1, 1})
+
+	// remove the second triangle of the top, opening a hole
+	facets = append(facets[:3], facets[4:]...)
+
+	// flip the first triangle of the front
+	facets[3].Vertex1, facets[3].Vertex2 = facets[3].Vertex2, facets[3].Vertex1
+
+	// add a copy of the last facet with a different vertex order, a sliver
+	// and a triangle of another box sharing an edge with the first one
+	last := facets[len(facets)-1]
+	facets = append(facets,
+		Facet{Vertex1: last.Vertex2, Vertex2: last.Vertex3, Vertex3: last.Vertex1},
+		Facet{Vertex1: Vector{0, 0, 0}, Vertex2: Vector{0.5, 0, 0}, Vertex3: Vector{1, 0, 0}},
+		Facet{Vertex1: Vector{0, 0, 0}, Vertex2: Vector{1, 1, 0}, Vertex3: Vector{1, 1, -1}})
+
+	r := Analyze(NewMesh(facets, 0))
+
+	if len(r.BoundaryEdges) == 0 {
+		t.Error("Expected boundary edges")
+	}
+	if !contains(r.BoundaryFacets, 2) {
+		t.Errorf("Expected facet 2 to border the hole, got %v", r.BoundaryFacets)
+	}
+	if !contains(r.InconsistentFacets, 3) {
+		t.Errorf("Expected facet 3 to be inconsistent, got %v", r.InconsistentFacets)
+	}
+	if !reflect.DeepEqual(r.DuplicateFacets, []int{11}) {
+		t.Errorf("Expected facet 11 to be a duplicate, got %v", r.DuplicateFacets)
+	}
+	if !reflect.DeepEqual(r.DegenerateFacets, []int{12}) {
+		t.Errorf("Expected facet 12 to be degenerate, got %v", r.DegenerateFacets)
+	}
+	if !contains(r.NonManifoldFacets, 13) {
+		t.Errorf("Expected facet 13 to be non-manifold, got %v", r.NonManifoldFacets)
+	}
+	if r.Watertight() || r.Printable() {
+		t.Error("Expected a broken mesh")
+	}
+}
+
+func contains(list []int, value int) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
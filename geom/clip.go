@@ -0,0 +1,711 @@
+package geom
+
+import (
+	"math"
+	"sort"
+)
+
+// clipScale is the number of integer units per millimeter used by the
+// clipping engine. Working on an integer grid makes the predicates exact.
+const clipScale = 1e4
+
+// ipoint is a point on the clipping grid.
+type ipoint struct {
+	x, y int64
+}
+
+func toIPoint(x, y float64) ipoint {
+	return ipoint{int64(math.Round(x * clipScale)), int64(math.Round(y * clipScale))}
+}
+
+func (p ipoint) point() Point {
+	return Point{float32(float64(p.x) / clipScale), float32(float64(p.y) / clipScale)}
+}
+
+func (p ipoint) less(q ipoint) bool {
+	return p.x < q.x || (p.x == q.x && p.y < q.y)
+}
+
+// cross returns the cross product of b-a and c-a, positive when c is to
+// the left of the line from a to b.
+func cross(a, b, c ipoint) int64 {
+	return (b.x-a.x)*(c.y-a.y) - (b.y-a.y)*(c.x-a.x)
+}
+
+// FillRule decides which parts of the plane closed paths enclose, from
+// how many times the paths wind around them, counting counter-clockwise
+// turns positively.
+type FillRule int
+
+const (
+	// EvenOdd encloses the parts wound around an odd number of times.
+	EvenOdd FillRule = iota
+	// NonZero encloses the parts wound around at all.
+	NonZero
+	// Positive encloses the parts wound around counter-clockwise.
+	Positive
+	// Negative encloses the parts wound around clockwise.
+	Negative
+)
+
+// String returns the name of the fill rule.
+func (r FillRule) String() string {
+	switch r {
+	case EvenOdd:
+		return "evenodd"
+	case NonZero:
+		return "nonzero"
+	case Positive:
+		return "positive"
+	case Negative:
+		return "negative"
+	}
+	return "unknown"
+}
+
+// inside returns true if the winding number is enclosed by the rule.
+func (r FillRule) inside(winding int) bool {
+	switch r {
+	case EvenOdd:
+		return winding%2 != 0
+	case NonZero:
+		return winding != 0
+	case Positive:
+		return winding > 0
+	case Negative:
+		return winding < 0
+	}
+	return false
+}
+
+// Union returns the region enclosed by either the subject or the clip
+// paths, under the fill rule.
+func Union(subject, clip []Path, rule FillRule) []Polygon {
+	return boolean(subject, clip, func(s, c bool) bool { return s || c }, rule)
+}
+
+// Intersection returns the region enclosed by both the subject and the
+// clip paths, under the fill rule.
+func Intersection(subject, clip []Path, rule FillRule) []Polygon {
+	return boolean(subject, clip, func(s, c bool) bool { return s && c }, rule)
+}
+
+// Difference returns the region enclosed by the subject paths but not by
+// the clip paths, under the fill rule.
+func Difference(subject, clip []Path, rule FillRule) []Polygon {
+	return boolean(subject, clip, func(s, c bool) bool { return s && !c }, rule)
+}
+
+// Xor returns the region enclosed by either the subject or the clip paths
+// but not both, under the fill rule.
+func Xor(subject, clip []Path, rule FillRule) []Polygon {
+	return boolean(subject, clip, func(s, c bool) bool { return s != c }, rule)
+}
+
+// PolygonPaths returns the paths of all the polygons, which enclose them
+// under both the NonZero and the Positive fill rules.
+func PolygonPaths(polygons []Polygon) []Path {
+	var paths []Path
+	for i := range polygons {
+		paths = append(paths, polygons[i].Paths()...)
+	}
+	return paths
+}
+
+// boolean combines the regions enclosed by the subject and clip paths.
+// Coordinates are rounded to a 0.1 micron grid, and the resulting polygons
+// only have the vertices needed to describe them.
+func boolean(subject, clip []Path, combine func(s, c bool) bool, rule FillRule) []Polygon {
+	var c clipper
+	c.addPaths(subject, 0)
+	c.addPaths(clip, 1)
+	return c.execute(func(w0, w1 int) bool { return combine(rule.inside(w0), rule.inside(w1)) })
+}
+
+// clipEdge is an edge of the input contours: from a to b, belonging to one
+// of two sets of contours (subject and clip).
+type clipEdge struct {
+	a, b ipoint
+	set  int
+}
+
+// clipper computes the regions defined by two sets of closed contours,
+// combining the winding numbers of the two sets with inside. It works by
+// splitting all the edges where they meet, so that they only touch at
+// their ends, and then keeping the edges with inside on one side only.
+type clipper struct {
+	edges []clipEdge
+}
+
+// addPaths adds closed paths to one of the sets.
+func (c *clipper) addPaths(paths []Path, set int) {
+	for _, path := range paths {
+		points := make([]ipoint, len(path))
+		for i, p := range path {
+			points[i] = toIPoint(float64(p[0]), float64(p[1]))
+		}
+		c.addContour(points, set)
+	}
+}
+
+// addContour adds a closed contour on the clipping grid to one of the sets.
+func (c *clipper) addContour(points []ipoint, set int) {
+	for i := range points {
+		a, b := points[i], points[(i+1)%len(points)]
+		if a != b {
+			c.edges = append(c.edges, clipEdge{a, b, set})
+		}
+	}
+}
+
+// execute returns the polygons covering the region where inside is true.
+func (c *clipper) execute(inside func(w0, w1 int) bool) []Polygon {
+	edges := splitEdges(c.edges)
+
+	// Merge overlapping edges, adding up how they change the winding
+	// numbers when crossing them from their right to their left, going
+	// from their lower to their higher end.
+	type merged struct {
+		lo, hi ipoint
+		delta  [2]int
+	}
+	index := make(map[[2]ipoint]int)
+	var unique []merged
+	for _, e := range edges {
+		lo, hi, d := e.a, e.b, 1
+		if hi.less(lo) {
+			lo, hi, d = hi, lo, -1
+		}
+		key := [2]ipoint{lo, hi}
+		i, ok := index[key]
+		if !ok {
+			i = len(unique)
+			index[key] = i
+			unique = append(unique, merged{lo: lo, hi: hi})
+		}
+		unique[i].delta[e.set] += d
+	}
+
+	// Edges cancelling out don't bound anything.
+	var live []clipEdge
+	var deltas [][2]int
+	for _, u := range unique {
+		if u.delta != [2]int{} {
+			live = append(live, clipEdge{a: u.lo, b: u.hi})
+			deltas = append(deltas, u.delta)
+		}
+	}
+
+	w := newWinder(live, deltas)
+	var result []clipEdge
+	for i, e := range live {
+		right := w.rightWinding(i)
+		left := [2]int{right[0] + deltas[i][0], right[1] + deltas[i][1]}
+		in, out := inside(left[0], left[1]), inside(right[0], right[1])
+		if in == out {
+			continue
+		}
+		if in {
+			result = append(result, clipEdge{a: e.a, b: e.b})
+		} else {
+			result = append(result, clipEdge{a: e.b, b: e.a})
+		}
+	}
+
+	return nestLoops(linkEdges(result))
+}
+
+// splitEdges splits the edges at their intersections, and wherever an
+// edge ends on another, until no two edges cross. Intersections are
+// rounded to the grid, which can create new ones, so this is repeated a
+// few times.
+func splitEdges(edges []clipEdge) []clipEdge {
+	for round := 0; round < 8; round++ {
+		splits := make(map[int][]ipoint)
+
+		order := make([]int, len(edges))
+		for i := range order {
+			order[i] = i
+		}
+		minX := func(e *clipEdge) int64 { return min64(e.a.x, e.b.x) }
+		maxX := func(e *clipEdge) int64 { return max64(e.a.x, e.b.x) }
+		sort.Slice(order, func(i, j int) bool { return minX(&edges[order[i]]) < minX(&edges[order[j]]) })
+
+		var active []int
+		for _, i := range order {
+			e := &edges[i]
+			kept := active[:0]
+			for _, j := range active {
+				if maxX(&edges[j]) >= minX(e) {
+					kept = append(kept, j)
+				}
+			}
+			active = kept
+			for _, j := range active {
+				intersect(e, &edges[j], i, j, splits)
+			}
+			active = append(active, i)
+		}
+
+		if len(splits) == 0 {
+			return edges
+		}
+
+		var split []clipEdge
+		for i, e := range edges {
+			points, ok := splits[i]
+			if !ok {
+				split = append(split, e)
+				continue
+			}
+			// order the points along the edge
+			dx, dy := e.b.x-e.a.x, e.b.y-e.a.y
+			sort.Slice(points, func(k, l int) bool {
+				return dx*(points[k].x-e.a.x)+dy*(points[k].y-e.a.y) < dx*(points[l].x-e.a.x)+dy*(points[l].y-e.a.y)
+			})
+			from := e.a
+			for _, p := range append(points, e.b) {
+				if p != from {
+					split = append(split, clipEdge{from, p, e.set})
+					from = p
+				}
+			}
+		}
+		edges = split
+	}
+	return edges
+}
+
+// intersect records where edges e and f, with indices i and j, need to be
+// split so that they only meet at their ends.
+func intersect(e, f *clipEdge, i, j int, splits map[int][]ipoint) {
+	if max64(e.a.y, e.b.y) < min64(f.a.y, f.b.y) || max64(f.a.y, f.b.y) < min64(e.a.y, e.b.y) {
+		return
+	}
+
+	d1, d2 := cross(e.a, e.b, f.a), cross(e.a, e.b, f.b)
+	d3, d4 := cross(f.a, f.b, e.a), cross(f.a, f.b, e.b)
+
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		// a proper crossing
+		t := float64(d3) / float64(d3-d4)
+		p := ipoint{
+			e.a.x + int64(math.Round(float64(e.b.x-e.a.x)*t)),
+			e.a.y + int64(math.Round(float64(e.b.y-e.a.y)*t)),
+		}
+		if p != e.a && p != e.b {
+			splits[i] = append(splits[i], p)
+		}
+		if p != f.a && p != f.b {
+			splits[j] = append(splits[j], p)
+		}
+		return
+	}
+
+	// an end of one edge on the other, which also covers overlaps
+	if d1 == 0 && onSegment(e, f.a) {
+		splits[i] = append(splits[i], f.a)
+	}
+	if d2 == 0 && onSegment(e, f.b) {
+		splits[i] = append(splits[i], f.b)
+	}
+	if d3 == 0 && onSegment(f, e.a) {
+		splits[j] = append(splits[j], e.a)
+	}
+	if d4 == 0 && onSegment(f, e.b) {
+		splits[j] = append(splits[j], e.b)
+	}
+}
+
+// onSegment returns true if p, known to be on the line through the edge,
+// is strictly between its ends.
+func onSegment(e *clipEdge, p ipoint) bool {
+	if p == e.a || p == e.b {
+		return false
+	}
+	return p.x >= min64(e.a.x, e.b.x) && p.x <= max64(e.a.x, e.b.x) &&
+		p.y >= min64(e.a.y, e.b.y) && p.y <= max64(e.a.y, e.b.y)
+}
+
+// winder computes the winding numbers next to the edges, casting rays
+// from their midpoints. Edges are bucketed into bands so that only the
+// ones that can be hit by a ray are looked at.
+type winder struct {
+	edges  []clipEdge
+	deltas [][2]int
+	rows   bands // for rays along x, by y
+	cols   bands // for rays along y, by x
+}
+
+func newWinder(edges []clipEdge, deltas [][2]int) *winder {
+	w := &winder{edges: edges, deltas: deltas}
+	w.rows = newBands(edges, func(p ipoint) int64 { return p.y })
+	w.cols = newBands(edges, func(p ipoint) int64 { return p.x })
+	return w
+}
+
+// rightWinding returns the winding numbers of both sets just to the right
+// of edge i, going from its a to its b end.
+func (w *winder) rightWinding(i int) [2]int {
+	e := &w.edges[i]
+	// work with doubled coordinates, so that the midpoint is on the grid
+	m := ipoint{e.a.x + e.b.x, e.a.y + e.b.y}
+
+	// Cast a ray towards +x, or towards +y for horizontal edges by
+	// rotating everything a quarter turn clockwise, which maps +y to +x.
+	horizontal := e.a.y == e.b.y
+	rotate := func(p ipoint) ipoint { return p }
+	candidates := w.rows.at(m.y)
+	if horizontal {
+		rotate = func(p ipoint) ipoint { return ipoint{p.y, -p.x} }
+		candidates = w.cols.at(m.x)
+	}
+	m = rotate(m)
+
+	var winding [2]int
+	for _, j := range candidates {
+		if j == i {
+			continue
+		}
+		f := &w.edges[j]
+		a := rotate(ipoint{2 * f.a.x, 2 * f.a.y})
+		b := rotate(ipoint{2 * f.b.x, 2 * f.b.y})
+		if a.y <= m.y {
+			if b.y > m.y && cross(a, b, m) > 0 {
+				winding[0] += w.deltas[j][0]
+				winding[1] += w.deltas[j][1]
+			}
+		} else if b.y <= m.y && cross(a, b, m) < 0 {
+			winding[0] -= w.deltas[j][0]
+			winding[1] -= w.deltas[j][1]
+		}
+	}
+
+	// The ray found the winding on its side of the edge: if that is the
+	// left side, cross the edge to get to the right one.
+	a, b := rotate(e.a), rotate(e.b)
+	if b.y < a.y {
+		winding[0] -= w.deltas[i][0]
+		winding[1] -= w.deltas[i][1]
+	}
+	return winding
+}
+
+// bands buckets edges by their extent along one axis.
+type bands struct {
+	min, size int64
+	buckets   [][]int
+	coord     func(ipoint) int64
+}
+
+func newBands(edges []clipEdge, coord func(ipoint) int64) bands {
+	b := bands{coord: coord}
+	if len(edges) == 0 {
+		return b
+	}
+	lo, hi := coord(edges[0].a), coord(edges[0].a)
+	for _, e := range edges {
+		lo = min64(lo, min64(coord(e.a), coord(e.b)))
+		hi = max64(hi, max64(coord(e.a), coord(e.b)))
+	}
+	count := int64(len(edges)/4 + 1)
+	b.min = 2 * lo
+	b.size = (2*(hi-lo))/count + 1
+	b.buckets = make([][]int, count)
+	for i, e := range edges {
+		from := (2*min64(coord(e.a), coord(e.b)) - b.min) / b.size
+		to := (2*max64(coord(e.a), coord(e.b)) - b.min) / b.size
+		for k := from; k <= to && k < count; k++ {
+			b.buckets[k] = append(b.buckets[k], i)
+		}
+	}
+	return b
+}
+
+// at returns the edges that may reach the doubled coordinate v.
+func (b *bands) at(v int64) []int {
+	k := (v - b.min) / b.size
+	if v < b.min || k >= int64(len(b.buckets)) {
+		return nil
+	}
+	return b.buckets[k]
+}
+
+// linkEdges joins edges meeting end to end into closed contours. Where
+// more than two edges meet, it turns as far left as possible, so that
+// regions touching at a point come out as separate contours.
+func linkEdges(edges []clipEdge) [][]ipoint {
+	outgoing := make(map[ipoint][]int)
+	for i, e := range edges {
+		outgoing[e.a] = append(outgoing[e.a], i)
+	}
+	used := make([]bool, len(edges))
+
+	var loops [][]ipoint
+	for start := range edges {
+		if used[start] {
+			continue
+		}
+		var points []ipoint
+		for i := start; i >= 0 && !used[i]; {
+			used[i] = true
+			e := edges[i]
+			points = append(points, e.a)
+
+			next := -1
+			for _, j := range outgoing[e.b] {
+				if used[j] && j != start {
+					continue
+				}
+				if next < 0 || turnsLeftOf(e, edges[j], edges[next]) {
+					next = j
+				}
+			}
+			i = next
+		}
+
+		for _, loop := range splitLoop(points) {
+			if loop = simplify(loop); len(loop) >= 3 {
+				loops = append(loops, loop)
+			}
+		}
+	}
+	return loops
+}
+
+// nestLoops builds polygons from contours going counter-clockwise around
+// regions and clockwise around holes, which do not cross. Every hole goes
+// in the smallest polygon containing the middle of one of its edges,
+// which is never on another contour.
+func nestLoops(loops [][]ipoint) []Polygon {
+	type outer struct {
+		loop []ipoint
+		area int64
+	}
+	var outers []outer
+	var holes [][]ipoint
+	for _, loop := range loops {
+		if area := loopArea(loop); area > 0 {
+			outers = append(outers, outer{loop, area})
+		} else if area < 0 {
+			holes = append(holes, loop)
+		}
+	}
+	sort.SliceStable(outers, func(i, j int) bool { return outers[i].area > outers[j].area })
+
+	polygons := make([]Polygon, len(outers))
+	for i, o := range outers {
+		polygons[i].Outer = loopPath(o.loop)
+	}
+	for _, hole := range holes {
+		m := ipoint{hole[0].x + hole[1].x, hole[0].y + hole[1].y}
+		for i := len(outers) - 1; i >= 0; i-- {
+			if loopContains(outers[i].loop, m) {
+				polygons[i].Holes = append(polygons[i].Holes, loopPath(hole))
+				break
+			}
+		}
+	}
+	return polygons
+}
+
+// loopArea returns twice the signed area of a contour.
+func loopArea(loop []ipoint) int64 {
+	var area int64
+	for i, p := range loop {
+		q := loop[(i+1)%len(loop)]
+		area += p.x*q.y - q.x*p.y
+	}
+	return area
+}
+
+// loopContains returns true if the point m, in doubled coordinates and not
+// on the contour, is inside it.
+func loopContains(loop []ipoint, m ipoint) bool {
+	winding := 0
+	for i, p := range loop {
+		q := loop[(i+1)%len(loop)]
+		a, b := ipoint{2 * p.x, 2 * p.y}, ipoint{2 * q.x, 2 * q.y}
+		if a.y <= m.y {
+			if b.y > m.y && cross(a, b, m) > 0 {
+				winding++
+			}
+		} else if b.y <= m.y && cross(a, b, m) < 0 {
+			winding--
+		}
+	}
+	return winding != 0
+}
+
+// loopPath converts a contour back to a path.
+func loopPath(loop []ipoint) Path {
+	path := make(Path, len(loop))
+	for i, p := range loop {
+		path[i] = p.point()
+	}
+	return path
+}
+
+// splitLoop splits a closed contour going through some vertex more than
+// once into loops going through each vertex once, so that a hole touching
+// the boundary of its polygon comes out as a hole.
+func splitLoop(points []ipoint) [][]ipoint {
+	var loops [][]ipoint
+	var stack []ipoint
+	seen := make(map[ipoint]int)
+	for _, p := range points {
+		if k, ok := seen[p]; ok {
+			loops = append(loops, append([]ipoint(nil), stack[k:]...))
+			for _, q := range stack[k+1:] {
+				delete(seen, q)
+			}
+			stack = stack[:k+1]
+			continue
+		}
+		seen[p] = len(stack)
+		stack = append(stack, p)
+	}
+	return append(loops, stack)
+}
+
+// turnsLeftOf returns true if, arriving along e, continuing along f turns
+// further left than continuing along g.
+func turnsLeftOf(e, f, g clipEdge) bool {
+	return turnAngle(e, f) > turnAngle(e, g)
+}
+
+// turnAngle returns the angle turned going from edge e to edge f, positive
+// to the left.
+func turnAngle(e, f clipEdge) float64 {
+	ex, ey := float64(e.b.x-e.a.x), float64(e.b.y-e.a.y)
+	fx, fy := float64(f.b.x-f.a.x), float64(f.b.y-f.a.y)
+	return math.Atan2(ex*fy-ey*fx, ex*fx+ey*fy)
+}
+
+// simplify removes the points of a contour lying on the line through
+// their neighbors.
+func simplify(points []ipoint) []ipoint {
+	for changed := true; changed && len(points) >= 3; {
+		changed = false
+		n := len(points)
+		kept := make([]ipoint, 0, n)
+		for i, p := range points {
+			prev := points[(i+n-1)%n]
+			if len(kept) > 0 {
+				prev = kept[len(kept)-1]
+			}
+			if cross(prev, p, points[(i+1)%n]) == 0 {
+				changed = true
+				continue
+			}
+			kept = append(kept, p)
+		}
+		points = kept
+	}
+	return points
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// ClipPaths returns the parts of the open paths inside the polygons, in
+// the order and the direction they come in. Parts running along the
+// boundary of the polygons may be kept or not.
+func ClipPaths(paths []Path, polygons []Polygon) []Path {
+	bounds := emptyBounds
+	for i := range polygons {
+		bounds = bounds.Union(polygons[i].Bounds())
+	}
+	inside := func(p Point) bool {
+		for i := range polygons {
+			if polygons[i].Contains(p) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var clipped []Path
+	for _, path := range paths {
+		var current Path
+		flush := func() {
+			if len(current) >= 2 {
+				clipped = append(clipped, current)
+			}
+			current = nil
+		}
+
+		for i := 0; i+1 < len(path); i++ {
+			a, b := path[i], path[i+1]
+			// where the segment crosses the polygons, as fractions of it
+			ts := []float64{0, 1}
+			if (Path{a, b}).Bounds().Overlaps(bounds) {
+				for j := range polygons {
+					for _, boundary := range polygons[j].Paths() {
+						ts = appendCrossings(ts, a, b, boundary)
+					}
+				}
+			}
+			sort.Float64s(ts)
+
+			for k := 0; k+1 < len(ts); k++ {
+				if ts[k+1]-ts[k] < 1e-9 {
+					continue
+				}
+				from, to := lerp(a, b, ts[k]), lerp(a, b, ts[k+1])
+				if !inside(lerp(a, b, (ts[k]+ts[k+1])/2)) {
+					flush()
+					continue
+				}
+				if len(current) == 0 {
+					current = append(current, from)
+				}
+				current = append(current, to)
+			}
+		}
+		flush()
+	}
+	return clipped
+}
+
+// appendCrossings appends where the segment from a to b crosses the
+// edges of the closed path, as fractions of the segment.
+func appendCrossings(ts []float64, a, b Point, path Path) []float64 {
+	ax, ay := float64(a[0]), float64(a[1])
+	dx, dy := float64(b[0])-ax, float64(b[1])-ay
+	for i := range path {
+		p, q := path[i], path[(i+1)%len(path)]
+		px, py := float64(p[0]), float64(p[1])
+		ex, ey := float64(q[0])-px, float64(q[1])-py
+		denominator := dx*ey - dy*ex
+		if denominator == 0 {
+			// parallel edges, which the midpoints take care of
+			continue
+		}
+		t := ((px-ax)*ey - (py-ay)*ex) / denominator
+		u := ((px-ax)*dy - (py-ay)*dx) / denominator
+		if t > 0 && t < 1 && u >= 0 && u <= 1 {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+// lerp returns the point a fraction t of the way from a to b.
+func lerp(a, b Point, t float64) Point {
+	return Point{
+		float32(float64(a[0]) + (float64(b[0])-float64(a[0]))*t),
+		float32(float64(a[1]) + (float64(b[1])-float64(a[1]))*t),
+	}
+}
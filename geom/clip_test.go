@@ -0,0 +1,236 @@
+package geom
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// checkPolygons checks the number of polygons and holes and the area of a
+// boolean operation result, and that it is oriented properly.
+func checkPolygons(t *testing.T, name string, result []Polygon, count, holes int, area float64) {
+	t.Helper()
+	if len(result) != count {
+		t.Errorf("%v: expected %v polygons, got %v: %+v", name, count, len(result), result)
+	}
+	n := 0
+	for _, p := range result {
+		n += len(p.Holes)
+		if p.Outer.Area() <= 0 {
+			t.Errorf("%v: outer path is clockwise", name)
+		}
+		for _, hole := range p.Holes {
+			if hole.Area() >= 0 {
+				t.Errorf("%v: hole is counter-clockwise", name)
+			}
+		}
+	}
+	if n != holes {
+		t.Errorf("%v: expected %v holes, got %v", name, holes, n)
+	}
+	if a := Area(result); math.Abs(a-area) > 1e-3 {
+		t.Errorf("%v: expected area %v, got %v", name, area, a)
+	}
+}
+
+func TestBooleanOverlapping(t *testing.T) {
+	a, b := []Path{square(0, 0, 2)}, []Path{square(1, 1, 2)}
+
+	checkPolygons(t, "union", Union(a, b, NonZero), 1, 0, 7)
+	checkPolygons(t, "intersection", Intersection(a, b, NonZero), 1, 0, 1)
+	checkPolygons(t, "difference", Difference(a, b, NonZero), 1, 0, 3)
+	checkPolygons(t, "xor", Xor(a, b, NonZero), 2, 0, 6)
+
+	if u := Union(a, b, NonZero); len(u) == 1 && len(u[0].Outer) != 8 {
+		t.Errorf("Expected %v vertices, got %v", 8, u[0].Outer)
+	}
+}
+
+func TestBooleanSharedEdge(t *testing.T) {
+	a, b := []Path{square(0, 0, 1)}, []Path{square(1, 0, 1)}
+
+	union := Union(a, b, NonZero)
+	checkPolygons(t, "union", union, 1, 0, 2)
+	if len(union) == 1 && len(union[0].Outer) != 4 {
+		t.Errorf("Expected the shared edge to vanish, got %v", union[0].Outer)
+	}
+	checkPolygons(t, "intersection", Intersection(a, b, NonZero), 0, 0, 0)
+	checkPolygons(t, "difference", Difference(a, b, NonZero), 1, 0, 1)
+	checkPolygons(t, "xor", Xor(a, b, NonZero), 1, 0, 2)
+}
+
+func TestBooleanPartiallySharedEdge(t *testing.T) {
+	a, b := []Path{square(0, 0, 2)}, []Path{{{1, -1}, {3, -1}, {3, 0}, {1, 0}}}
+
+	checkPolygons(t, "union", Union(a, b, NonZero), 1, 0, 6)
+	checkPolygons(t, "intersection", Intersection(a, b, NonZero), 0, 0, 0)
+	checkPolygons(t, "difference", Difference(a, b, NonZero), 1, 0, 4)
+
+	// collinear edges overlapping from the inside
+	c := []Path{square(0.5, 0, 1)}
+	checkPolygons(t, "inner union", Union(a, c, NonZero), 1, 0, 4)
+	checkPolygons(t, "inner difference", Difference(a, c, NonZero), 1, 0, 3)
+}
+
+func TestBooleanTouchingVertex(t *testing.T) {
+	a, b := []Path{square(0, 0, 1)}, []Path{square(1, 1, 1)}
+
+	union := Union(a, b, NonZero)
+	checkPolygons(t, "union", union, 2, 0, 2)
+	for _, p := range union {
+		if len(p.Outer) != 4 {
+			t.Errorf("Expected separate squares, got %v", p.Outer)
+		}
+	}
+	checkPolygons(t, "intersection", Intersection(a, b, NonZero), 0, 0, 0)
+
+	// a vertex of one on an edge of the other
+	c := []Path{{{1, 0.5}, {2, 0}, {2, 1}}}
+	checkPolygons(t, "edge union", Union(a, c, NonZero), 2, 0, 1.5)
+}
+
+func TestBooleanIdentical(t *testing.T) {
+	a := []Path{square(0, 0, 1)}
+
+	checkPolygons(t, "union", Union(a, a, NonZero), 1, 0, 1)
+	checkPolygons(t, "intersection", Intersection(a, a, NonZero), 1, 0, 1)
+	checkPolygons(t, "difference", Difference(a, a, NonZero), 0, 0, 0)
+	checkPolygons(t, "xor", Xor(a, a, NonZero), 0, 0, 0)
+}
+
+func TestBooleanHoles(t *testing.T) {
+	a := []Path{square(0, 0, 4)}
+
+	// cutting a hole, then filling it back
+	frame := Difference(a, []Path{square(1, 1, 2)}, NonZero)
+	checkPolygons(t, "hole", frame, 1, 1, 12)
+	checkPolygons(t, "filled", Union(PolygonPaths(frame), []Path{square(1, 1, 2)}, NonZero), 1, 0, 16)
+
+	// a hole touching the outer boundary at a vertex
+	notch := Difference(a, []Path{{{0, 0}, {2, 1}, {1, 2}}}, NonZero)
+	checkPolygons(t, "notch", notch, 1, 1, 14.5)
+	if !polygonsContain(notch, Point{3, 3}) || polygonsContain(notch, Point{1, 1}) {
+		t.Error("Wrong containment")
+	}
+
+	// a hole touching the outer boundary along an edge becomes a notch
+	bite := Difference(a, []Path{square(0, 1, 1)}, NonZero)
+	checkPolygons(t, "bite", bite, 1, 0, 15)
+
+	// an island in a hole
+	island := Union(PolygonPaths(frame), []Path{square(1.5, 1.5, 1)}, NonZero)
+	checkPolygons(t, "island", island, 2, 1, 13)
+}
+
+func TestBooleanFillRules(t *testing.T) {
+	// two overlapping squares in the same set, and one going clockwise
+	paths := []Path{square(0, 0, 2), square(1, 1, 2), square(5, 0, 1).Reverse()}
+
+	checkPolygons(t, "evenodd", Union(paths, nil, EvenOdd), 3, 0, 7)
+	checkPolygons(t, "nonzero", Union(paths, nil, NonZero), 2, 0, 8)
+	checkPolygons(t, "positive", Union(paths, nil, Positive), 1, 0, 7)
+	checkPolygons(t, "negative", Union(paths, nil, Negative), 1, 0, 1)
+
+	// a self-intersecting bow tie
+	bowtie := []Path{{{0, 0}, {2, 2}, {2, 0}, {0, 2}}}
+	checkPolygons(t, "bowtie", Union(bowtie, nil, EvenOdd), 2, 0, 2)
+	checkPolygons(t, "bowtie positive", Union(bowtie, nil, Positive), 1, 0, 1)
+
+	// a path going around twice
+	twice := []Path{append(square(0, 0, 1), square(0, 0, 1)...)}
+	checkPolygons(t, "twice evenodd", Union(twice, nil, EvenOdd), 0, 0, 0)
+	checkPolygons(t, "twice nonzero", Union(twice, nil, NonZero), 1, 0, 1)
+}
+
+func TestBooleanEmpty(t *testing.T) {
+	a := []Path{square(0, 0, 1)}
+
+	checkPolygons(t, "empty union", Union(nil, nil, NonZero), 0, 0, 0)
+	checkPolygons(t, "empty clip", Difference(a, nil, NonZero), 1, 0, 1)
+	checkPolygons(t, "empty subject", Intersection(nil, a, NonZero), 0, 0, 0)
+
+	// paths enclosing nothing
+	flat := []Path{{{0, 0}, {1, 0}, {2, 0}}, {{0, 0}, {0, 0}, {0, 0}}}
+	checkPolygons(t, "flat", Union(flat, a, NonZero), 1, 0, 1)
+}
+
+func TestBooleanAreas(t *testing.T) {
+	// random triangles and quadrilaterals, checking that the areas of the
+	// results add up, up to the rounding of intersections to the grid
+	r := rand.New(rand.NewSource(1))
+	shape := func() []Path {
+		var paths []Path
+		for i := 0; i < 3; i++ {
+			path := make(Path, 3+r.Intn(2))
+			for j := range path {
+				path[j] = Point{float32(r.Intn(20)) / 2, float32(r.Intn(20)) / 2}
+			}
+			paths = append(paths, path)
+		}
+		return paths
+	}
+
+	for i := 0; i < 200; i++ {
+		a, b := shape(), shape()
+		areaA := Area(Union(a, nil, EvenOdd))
+		areaB := Area(Union(b, nil, EvenOdd))
+		union := Area(Union(a, b, EvenOdd))
+		intersection := Area(Intersection(a, b, EvenOdd))
+		difference := Area(Difference(a, b, EvenOdd))
+		xor := Area(Xor(a, b, EvenOdd))
+
+		if math.Abs(union+intersection-areaA-areaB) > 1e-2 {
+			t.Fatalf("%v, %v: union %v and intersection %v don't add up to %v and %v", a, b, union, intersection, areaA, areaB)
+		}
+		if math.Abs(difference+intersection-areaA) > 1e-2 {
+			t.Fatalf("%v, %v: difference %v and intersection %v don't add up to %v", a, b, difference, intersection, areaA)
+		}
+		if math.Abs(xor+intersection-union) > 1e-2 {
+			t.Fatalf("%v, %v: xor %v and intersection %v don't add up to %v", a, b, xor, intersection, union)
+		}
+	}
+}
+
+// polygonsContain returns true if one of the polygons contains the point.
+func polygonsContain(polygons []Polygon, point Point) bool {
+	for i := range polygons {
+		if polygons[i].Contains(point) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestClipPaths(t *testing.T) {
+	frame := Difference([]Path{square(0, 0, 4)}, []Path{square(1, 1, 2)}, NonZero)
+
+	lines := []Path{
+		{{-1, 2}, {5, 2}},              // through the hole
+		{{-1, 0.5}, {2, 0.5}, {2, -1}}, // in and out with a turn
+		{{5, 5}, {6, 6}},               // outside
+		{{0.5, 3.5}, {3.5, 3.5}},       // inside
+	}
+	clipped := ClipPaths(lines, frame)
+
+	expected := []Path{
+		{{0, 2}, {1, 2}},
+		{{3, 2}, {4, 2}},
+		{{0, 0.5}, {2, 0.5}, {2, 0}},
+		{{0.5, 3.5}, {3.5, 3.5}},
+	}
+	if len(clipped) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, clipped)
+	}
+	for i := range expected {
+		if len(clipped[i]) != len(expected[i]) {
+			t.Errorf("Expected %v, got %v", expected[i], clipped[i])
+			continue
+		}
+		for j := range expected[i] {
+			if distance(clipped[i][j], expected[i][j]) > 1e-5 {
+				t.Errorf("Expected %v, got %v", expected[i], clipped[i])
+				break
+			}
+		}
+	}
+}
@@ -1,7 +1,8 @@
 package geom
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
 	"math"
 )
 
@@ -17,213 +18,190 @@ type Facet struct {
 
 type Point [2]float32
 
+// EdgeKey identifies where a segment endpoint comes from: the two vertices
+// of the facet edge crossing the slicing plane, lower one first, or twice
+// the same vertex when it lies on the plane. Facets sharing an edge produce
+// segments with the same key at their common endpoint.
+type EdgeKey [2]Vector
+
 type Segment struct {
-	Start   Point
-	End     Point
-	Normal  *Vector
-	Visited bool
+	Start     Point
+	End       Point
+	Normal    *Vector
+	StartEdge EdgeKey
+	EndEdge   EdgeKey
 }
 
 type Path []Point
 
-func SliceAngle(a, b, c, normal *Vector, layerZ float32) Segment {
-	s := new(Segment)
-	s.Start = *new(Point)
-	s.End = *new(Point)
-	s.Normal = normal
+// SliceFacet intersects the facet with the horizontal plane at height z,
+// returning the segment where they meet and whether there is one.
+//
+// Vertices lying exactly on the plane are treated as if they were
+// infinitesimally above it (a symbolic perturbation of the plane), so that
+// every facet either crosses the plane along two of its edges or doesn't
+// touch it at all. Vertices, edges and faces on the plane then need no
+// special casing, and the facets sharing an edge always agree on whether
+// and where it crosses the plane: the contours come out closed, without
+// duplicated or missing segments.
+//
+// The segment is oriented so that, seen from above, the inside of the
+// facet's solid is on its left, according to the vertex winding.
+func SliceFacet(facet *Facet, z float64) (Segment, bool) {
+	vertices := facet.Vertices()
+
+	var above [3]bool
+	count := 0
+	for i, v := range vertices {
+		above[i] = float64(v[2]) >= z
+		if above[i] {
+			count++
+		}
+	}
+	if count == 0 || count == 3 {
+		return Segment{}, false
+	}
 
-	dlaz := layerZ - a[2]
-	dbaz := b[2] - a[2]
-	dcaz := c[2] - a[2]
+	// Going around the facet, one edge goes up through the plane and one
+	// comes down through it: walking from the point where the facet comes
+	// down to the one where it goes up leaves the inside on the left.
+	var s Segment
+	for i := 0; i < 3; i++ {
+		a, b := &vertices[i], &vertices[(i+1)%3]
+		if !above[i] && above[(i+1)%3] {
+			s.End, s.EndEdge = crossing(a, b, z)
+		} else if above[i] && !above[(i+1)%3] {
+			s.Start, s.StartEdge = crossing(b, a, z)
+		}
+	}
+	return s, true
+}
 
-	t1 := dlaz / dbaz
-	s.Start[0] = t1*(b[0]-a[0]) + a[0]
-	s.Start[1] = t1*(b[1]-a[1]) + a[1]
+// crossing returns the point where the edge from below, which is under the
+// plane at height z, to above, which is on or over it, crosses the plane,
+// and the key identifying it. Since it is always computed from the lower
+// end, the facets sharing an edge get exactly the same point.
+func crossing(below, above *Vector, z float64) (Point, EdgeKey) {
+	if float64(above[2]) == z {
+		return Point{above[0], above[1]}, EdgeKey{*above, *above}
+	}
+	t := (z - float64(below[2])) / (float64(above[2]) - float64(below[2]))
+	return Point{
+		float32(float64(below[0]) + t*(float64(above[0])-float64(below[0]))),
+		float32(float64(below[1]) + t*(float64(above[1])-float64(below[1]))),
+	}, EdgeKey{*below, *above}
+}
 
-	t2 := dlaz / dcaz
-	s.End[0] = t2*(c[0]-a[0]) + a[0]
-	s.End[1] = t2*(c[1]-a[1]) + a[1]
+// Slicer accumulates the segments obtained by slicing facets with the layer
+// planes. Facets can be added one at a time as they are read, so the model
+// never needs to be held in memory as a whole.
+type Slicer struct {
+	LayerHeight float64
 
-	return *s
+	// Logger receives the debug traces of the slicer, if set.
+	Logger *slog.Logger
+
+	logBase  *slog.Logger // the logger sliceLog was derived from
+	sliceLog *slog.Logger
+
+	segments       map[int32][]Segment
+	globalMinLayer int32
+	globalMaxLayer int32
+	sliced         bool // whether any segment was found
+}
+
+// NewSlicer returns a new instance of Slicer.
+func NewSlicer(layerHeight float64) *Slicer {
+	return &Slicer{
+		LayerHeight: layerHeight,
+		segments:    make(map[int32][]Segment),
+	}
 }
 
-func FacetsByLayer(facets *[]Facet, layerHeight float64) (*map[int32]map[Point]Segment, *map[int32][]Segment, int32, int32) {
-	m := make(map[int32]map[Point]Segment)
-	segments := make(map[int32][]Segment)
-	globalMinLayer := int32(0)
-	globalMaxLayer := int32(0)
+// Result returns the segments of each layer, in the order they were found,
+// along with the layer range.
+func (s *Slicer) Result() (*map[int32][]Segment, int32, int32) {
+	return &s.segments, s.globalMinLayer, s.globalMaxLayer
+}
 
+func FacetsByLayer(facets *[]Facet, layerHeight float64) (*map[int32][]Segment, int32, int32) {
+	s := NewSlicer(layerHeight)
 	for _, facet := range *facets {
-		if facet.Normal[0] == 0 && facet.Normal[1] == 0 {
-			// degenerate facet parallel to the slicing plane, so we ignore it for now
-			// TODO: I think we need this to understand roofs, so ignoring it is not
-			// ideal, we need to find a way to record this info somehow
-			fmt.Println("Skipping facet:", facet)
+		s.AddFacet(facet)
+	}
+	return s.Result()
+}
+
+// AddFacet slices a single facet and records the segments it produces.
+func (s *Slicer) AddFacet(facet Facet) {
+	// Look at the vertices rather than the normal, which many exporters get wrong.
+	normal := facet.ComputeNormal()
+	facet.Normal = normal
+
+	minLayer, maxLayer := layerRange(&facet, s.LayerHeight)
+	for layer := minLayer; layer <= maxLayer; layer += 1 {
+		segment, ok := SliceFacet(&facet, s.LayerZ(layer))
+		if !ok {
 			continue
 		}
-
-		var max *Vector
-		var mid *Vector
-		var min *Vector
-		var maxIndex int
-		var midIndex int
-		var minIndex int
-		facetType := "general"
-
-		if facet.Vertex1[2] > facet.Vertex2[2] {
-			max = &facet.Vertex1
-			maxIndex = 1
-			mid = &facet.Vertex2
-			midIndex = 2
-		} else {
-			max = &facet.Vertex2
-			maxIndex = 2
-			mid = &facet.Vertex1
-			midIndex = 1
+		if log := s.log(); log.Enabled(context.Background(), slog.LevelDebug) {
+			log.Debug("obtained segment", "layer", layer, "start", segment.Start, "end", segment.End)
 		}
-
-		if facet.Vertex3[2] > max[2] {
-			min = mid
-			minIndex = midIndex
-			mid = max
-			midIndex = maxIndex
-			max = &facet.Vertex3
-			maxIndex = 3
-		} else if facet.Vertex3[2] > mid[2] {
-			min = mid
-			minIndex = midIndex
-			mid = &facet.Vertex3
-			midIndex = 3
-		} else {
-			min = &facet.Vertex3
-			minIndex = 3
+		if segment.Start == segment.End {
+			continue
 		}
+		segment.Normal = &normal
+		s.segments[layer] = append(s.segments[layer], segment)
 
-		if min[2] == mid[2] {
-			facetType = "resting_top"
-		} else if max[2] == mid[2] {
-			facetType = "resting_bottom"
+		if !s.sliced || layer < s.globalMinLayer {
+			s.globalMinLayer = layer
 		}
-
-		fmt.Println(facet, facetType)
-		fmt.Println(min, mid, max, minIndex, midIndex, maxIndex)
-
-		// lower part of the facet
-		if facetType != "resting_top" {
-			fmt.Println("Processing lower part of the facet")
-			minLayer := int32(math.Ceil(float64(min[2]) / layerHeight))
-			midLayerBelow := int32(math.Floor(float64(mid[2]) / layerHeight))
-
-			if minLayer < globalMinLayer {
-				globalMinLayer = minLayer
-			}
-
-			origin := min
-
-			var right *Vector
-			var left *Vector
-
-			if midIndex == (minIndex+1)%3 {
-				fmt.Println("mid to the left")
-				right = max
-				left = mid
-			} else {
-				fmt.Println("mid to the right")
-				right = mid
-				left = max
-			}
-
-			for layer := minLayer; layer <= midLayerBelow; layer += 1 {
-				fmt.Println("slicing layer ", layer)
-				layerSegments, ok := m[layer]
-				if !ok {
-					layerSegments = make(map[Point]Segment)
-					m[layer] = layerSegments
-				}
-				segment := SliceAngle(origin, right, left, &facet.Normal, float32(layer)*float32(layerHeight))
-				fmt.Println("obtained segment: ", segment)
-				if segment.Start[0] == segment.End[0] && segment.Start[1] == segment.End[1] {
-					fmt.Println("degenerate segment: skipping")
-				} else {
-					layerSegments[segment.Start] = segment
-					segments[layer] = append(segments[layer], segment)
-				}
-			}
+		if !s.sliced || layer > s.globalMaxLayer {
+			s.globalMaxLayer = layer
 		}
+		s.sliced = true
+	}
+}
 
-		// upper part of the facet
-		if facetType != "resting_bottom" {
-			fmt.Println("Processing upper part of the facet")
-			midLayerAbove := int32(math.Ceil(float64(mid[2]) / layerHeight))
-			maxLayer := int32(math.Floor(float64(max[2]) / layerHeight))
-
-			if maxLayer > globalMaxLayer {
-				globalMaxLayer = maxLayer
-			}
-
-			origin := max
-
-			var right *Vector
-			var left *Vector
-
-			if midIndex == (maxIndex+1)%3 {
-				fmt.Println("mid to the right")
-				right = mid
-				left = min
-			} else {
-				fmt.Println("mid to the left")
-				right = min
-				left = mid
-			}
-
-			for layer := midLayerAbove; layer <= maxLayer; layer += 1 {
-				fmt.Println("slicing layer ", layer)
-				layerSegments, ok := m[layer]
-				if !ok {
-					layerSegments = make(map[Point]Segment)
-					m[layer] = layerSegments
-				}
-				segment := SliceAngle(origin, right, left, &facet.Normal, float32(layer)*float32(layerHeight))
-				fmt.Println("obtained segment: ", segment)
-				if segment.Start[0] == segment.End[0] && segment.Start[1] == segment.End[1] {
-					fmt.Println("degenerate segment: skipping")
-				} else {
-					layerSegments[segment.Start] = segment
-					segments[layer] = append(segments[layer], segment)
-				}
-			}
+// log returns the logger for the traces of the slicer.
+func (s *Slicer) log() *slog.Logger {
+	if s.sliceLog == nil || s.Logger != s.logBase {
+		s.logBase, s.sliceLog = s.Logger, subsystemLogger(s.Logger, "slice")
+	}
+	return s.sliceLog
+}
+
+// layerRange returns the range of layers whose planes may cut the facet.
+func layerRange(facet *Facet, layerHeight float64) (int32, int32) {
+	min, max := facet.Vertex1[2], facet.Vertex1[2]
+	for _, v := range []Vector{facet.Vertex2, facet.Vertex3} {
+		if v[2] < min {
+			min = v[2]
+		}
+		if v[2] > max {
+			max = v[2]
 		}
 	}
 
-	return &m, &segments, globalMinLayer, globalMaxLayer
+	// The range is widened by a layer on each side so that rounding can't
+	// make us miss a plane: SliceFacet decides exactly which ones it crosses.
+	minLayer := int32(math.Ceil(float64(min)/layerHeight)) - 1
+	maxLayer := int32(math.Floor(float64(max)/layerHeight)) + 1
+	return minLayer, maxLayer
 }
 
-func PathsFromSegments(segments map[Point]Segment) *[]Path {
-	var paths []Path
-	for point, segment := range segments {
-		fmt.Println(segment)
-		if segment.Visited {
-			continue
-		}
-		start := point
-		segment.Visited = true
-		s := segment
-		var path Path
-		path = append(path, segment.Start)
-		for {
-			s, ok := segments[s.End]
-			if !ok {
-				fmt.Println("path doesn't close!")
-				break
-			}
-			s.Visited = true
-			path = append(path, s.Start)
-			if s.End == start {
-				// path closed, so we're done with it
-				break
-			}
+// Layers returns the layers with at least a segment, in increasing order.
+func (s *Slicer) Layers() []LayerSegments {
+	var layers []LayerSegments
+	for layer := s.globalMinLayer; s.sliced && layer <= s.globalMaxLayer; layer++ {
+		if segments := s.segments[layer]; len(segments) > 0 {
+			layers = append(layers, LayerSegments{Index: layer, Z: s.LayerZ(layer), Segments: segments})
 		}
-		paths = append(paths, path)
 	}
-	return &paths
+	return layers
+}
+
+// LayerZ returns the height of the plane slicing the given layer.
+func (s *Slicer) LayerZ(layer int32) float64 {
+	return float64(layer) * s.LayerHeight
 }
@@ -0,0 +1,67 @@
+package geom
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// subsystemLogger returns the logger for a subsystem, deriving it from l,
+// the logger given in the options of an operation. Without one, the traces
+// are discarded.
+func subsystemLogger(l *slog.Logger, subsystem string) *slog.Logger {
+	if l == nil {
+		l = slog.New(slog.DiscardHandler)
+	}
+	return l.With("subsystem", subsystem)
+}
+
+// SubsystemHandler is a slog.Handler passing on only the records of the
+// enabled subsystems, so that traces can be turned on selectively.
+type SubsystemHandler struct {
+	handler   slog.Handler
+	enabled   map[string]bool
+	subsystem string
+}
+
+// NewSubsystemHandler returns a handler passing on to h the records of the
+// given subsystems, or of all of them if "all" is one of them. Records not
+// belonging to any subsystem, and the warnings and errors of all of them,
+// are always passed on. The subsystems of the package are "slice",
+// "stitch", "repair" and "offset", named by the "subsystem" attribute of
+// their records.
+func NewSubsystemHandler(h slog.Handler, subsystems ...string) *SubsystemHandler {
+	enabled := make(map[string]bool)
+	for _, s := range subsystems {
+		enabled[strings.TrimSpace(s)] = true
+	}
+	return &SubsystemHandler{handler: h, enabled: enabled}
+}
+
+func (h *SubsystemHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level < slog.LevelWarn && h.subsystem != "" && !h.enabled[h.subsystem] && !h.enabled["all"] {
+		return false
+	}
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *SubsystemHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *SubsystemHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derived := *h
+	for _, a := range attrs {
+		if a.Key == "subsystem" {
+			derived.subsystem = a.Value.String()
+		}
+	}
+	derived.handler = h.handler.WithAttrs(attrs)
+	return &derived
+}
+
+func (h *SubsystemHandler) WithGroup(name string) slog.Handler {
+	derived := *h
+	derived.handler = h.handler.WithGroup(name)
+	return &derived
+}
@@ -0,0 +1,60 @@
+package geom
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlicerLogger(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewSlicer(0.5)
+	s.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	for _, f := range box(Vector{0, 0, 0}, Vector{1, 1, 1}) {
+		s.AddFacet(f)
+	}
+
+	if !strings.Contains(buf.String(), "subsystem=slice") {
+		t.Errorf("Expected slicing traces, got %q", buf.String())
+	}
+}
+
+func TestSubsystemHandler(t *testing.T) {
+	var buf bytes.Buffer
+	text := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	logger := slog.New(NewSubsystemHandler(text, "stitch", "offset"))
+
+	s := NewSlicer(0.5)
+	s.Logger = logger
+	for _, f := range box(Vector{0, 0, 0}, Vector{1, 1, 1}) {
+		s.AddFacet(f)
+	}
+	segments := s.Layers()[0].Segments
+	Stitch(segments[1:], StitchOptions{Logger: logger})
+	Repair(box(Vector{0, 0, 0}, Vector{1, 1, 1}), RepairOptions{Logger: logger})
+	Offset([]Polygon{{Outer: square(0, 0, 1)}}, 0.1, OffsetOptions{Logger: logger})
+
+	for subsystem, expected := range map[string]bool{"slice": false, "repair": false, "stitch": true, "offset": true} {
+		if strings.Contains(buf.String(), "subsystem="+subsystem) != expected {
+			t.Errorf("Expected traces of %v to be %v, got %q", subsystem, expected, buf.String())
+		}
+	}
+
+	// warnings get through whatever their subsystem
+	buf.Reset()
+	logger.With("subsystem", "layers").Warn("open paths")
+	if !strings.Contains(buf.String(), "subsystem=layers") {
+		t.Errorf("Expected the warning to be passed on, got %q", buf.String())
+	}
+}
+
+func TestNoLogger(t *testing.T) {
+	// Without loggers, nothing is traced and nothing breaks.
+	s := NewSlicer(0.5)
+	for _, f := range box(Vector{0, 0, 0}, Vector{1, 1, 1}) {
+		s.AddFacet(f)
+	}
+	Stitch(s.Layers()[0].Segments[1:], StitchOptions{})
+	Repair(box(Vector{0, 0, 0}, Vector{1, 1, 1}), RepairOptions{})
+}
@@ -0,0 +1,190 @@
+package geom
+
+import (
+	"math"
+	"sort"
+)
+
+// Edge identifies an undirected edge of a Mesh by the indices of its two
+// vertices, the smaller one first.
+type Edge [2]int
+
+// NewEdge returns the edge between the vertices a and b.
+func NewEdge(a, b int) Edge {
+	if a > b {
+		a, b = b, a
+	}
+	return Edge{a, b}
+}
+
+// Mesh is an indexed triangle mesh: every distinct vertex is stored once
+// and triangles refer to them by index. Triangle i is built from facet i
+// of the facets the mesh was created from, so indices can be used to refer
+// back to the original facets.
+type Mesh struct {
+	Vertices  []Vector
+	Triangles [][3]int
+	Normals   []Vector
+
+	edges map[Edge][]int // the triangles sharing each edge
+}
+
+// NewMesh builds a Mesh from the facets, welding together vertices closer
+// than tolerance to each other. With a zero tolerance only vertices with
+// exactly the same coordinates are shared.
+func NewMesh(facets []Facet, tolerance float32) *Mesh {
+	m := &Mesh{
+		Triangles: make([][3]int, len(facets)),
+		Normals:   make([]Vector, len(facets)),
+		edges:     make(map[Edge][]int),
+	}
+
+	w := newWelder(tolerance)
+	for i := range facets {
+		for j, v := range facets[i].Vertices() {
+			m.Triangles[i][j] = w.weld(&m.Vertices, v)
+		}
+		m.Normals[i] = facets[i].Normal
+	}
+
+	for i, t := range m.Triangles {
+		for j := 0; j < 3; j++ {
+			a, b := t[j], t[(j+1)%3]
+			if a == b {
+				// the edge collapsed to a point while welding
+				continue
+			}
+			e := NewEdge(a, b)
+			m.edges[e] = append(m.edges[e], i)
+		}
+	}
+
+	return m
+}
+
+// Facet returns triangle i as a Facet.
+func (m *Mesh) Facet(i int) Facet {
+	t := m.Triangles[i]
+	return Facet{
+		Normal:  m.Normals[i],
+		Vertex1: m.Vertices[t[0]],
+		Vertex2: m.Vertices[t[1]],
+		Vertex3: m.Vertices[t[2]],
+	}
+}
+
+// Facets returns all the triangles of the mesh as facets.
+func (m *Mesh) Facets() []Facet {
+	facets := make([]Facet, len(m.Triangles))
+	for i := range m.Triangles {
+		facets[i] = m.Facet(i)
+	}
+	return facets
+}
+
+// Edges returns all the edges of the mesh, sorted.
+func (m *Mesh) Edges() []Edge {
+	edges := make([]Edge, 0, len(m.edges))
+	for e := range m.edges {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+	return edges
+}
+
+// EdgeTriangles returns the indices of the triangles sharing the edge, in
+// increasing order.
+func (m *Mesh) EdgeTriangles(e Edge) []int {
+	return m.edges[e]
+}
+
+// Neighbors returns the indices of the triangles sharing an edge with
+// triangle i, in increasing order and without repetitions.
+func (m *Mesh) Neighbors(i int) []int {
+	var neighbors []int
+	t := m.Triangles[i]
+	for j := 0; j < 3; j++ {
+		if t[j] == t[(j+1)%3] {
+			continue
+		}
+		for _, n := range m.edges[NewEdge(t[j], t[(j+1)%3])] {
+			if n != i {
+				neighbors = append(neighbors, n)
+			}
+		}
+	}
+	sort.Ints(neighbors)
+
+	unique := neighbors[:0]
+	for k, n := range neighbors {
+		if k == 0 || n != neighbors[k-1] {
+			unique = append(unique, n)
+		}
+	}
+	return unique
+}
+
+// welder finds the index of already seen vertices within a tolerance,
+// hashing them into a grid of cells as large as the tolerance so that only
+// the neighboring cells need to be searched.
+type welder struct {
+	tolerance float32
+	exact     map[Vector]int
+	cells     map[[3]int64][]int
+}
+
+func newWelder(tolerance float32) *welder {
+	return &welder{
+		tolerance: tolerance,
+		exact:     make(map[Vector]int),
+		cells:     make(map[[3]int64][]int),
+	}
+}
+
+// weld returns the index of the vertex in vertices matching v, appending v
+// if there is none.
+func (w *welder) weld(vertices *[]Vector, v Vector) int {
+	if i, ok := w.exact[v]; ok {
+		return i
+	}
+
+	if w.tolerance > 0 {
+		cell := w.cell(v)
+		best, bestDistance := -1, w.tolerance
+		for dx := int64(-1); dx <= 1; dx++ {
+			for dy := int64(-1); dy <= 1; dy++ {
+				for dz := int64(-1); dz <= 1; dz++ {
+					neighbor := [3]int64{cell[0] + dx, cell[1] + dy, cell[2] + dz}
+					for _, i := range w.cells[neighbor] {
+						if d := (*vertices)[i].Sub(v).Length(); d <= bestDistance {
+							best, bestDistance = i, d
+						}
+					}
+				}
+			}
+		}
+		if best >= 0 {
+			w.exact[v] = best
+			return best
+		}
+		w.cells[cell] = append(w.cells[cell], len(*vertices))
+	}
+
+	i := len(*vertices)
+	*vertices = append(*vertices, v)
+	w.exact[v] = i
+	return i
+}
+
+func (w *welder) cell(v Vector) [3]int64 {
+	var cell [3]int64
+	for j := 0; j < 3; j++ {
+		cell[j] = int64(math.Floor(float64(v[j] / w.tolerance)))
+	}
+	return cell
+}
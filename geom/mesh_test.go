@@ -0,0 +1,87 @@
+package geom
+
+import (
+	"testing"
+)
+
+// box returns the facets of an axis aligned box, wound counter-clockwise
+// when seen from the outside.
+func box(min, max Vector) []Facet {
+	corner := func(x, y, z int) Vector {
+		c := min
+		if x == 1 {
+			c[0] = max[0]
+		}
+		if y == 1 {
+			c[1] = max[1]
+		}
+		if z == 1 {
+			c[2] = max[2]
+		}
+		return c
+	}
+
+	quads := [][4][3]int{
+		{{0, 0, 0}, {0, 1, 0}, {1, 1, 0}, {1, 0, 0}}, // bottom
+		{{0, 0, 1}, {1, 0, 1}, {1, 1, 1}, {0, 1, 1}}, // top
+		{{0, 0, 0}, {1, 0, 0}, {1, 0, 1}, {0, 0, 1}}, // front
+		{{0, 1, 0}, {0, 1, 1}, {1, 1, 1}, {1, 1, 0}}, // back
+		{{0, 0, 0}, {0, 0, 1}, {0, 1, 1}, {0, 1, 0}}, // left
+		{{1, 0, 0}, {1, 1, 0}, {1, 1, 1}, {1, 0, 1}}, // right
+	}
+	normals := []Vector{{0, 0, -1}, {0, 0, 1}, {0, -1, 0}, {0, 1, 0}, {-1, 0, 0}, {1, 0, 0}}
+
+	var facets []Facet
+	for i, q := range quads {
+		var v [4]Vector
+		for j, c := range q {
+			v[j] = corner(c[0], c[1], c[2])
+		}
+		facets = append(facets,
+			Facet{Normal: normals[i], Vertex1: v[0], Vertex2: v[1], Vertex3: v[2]},
+			Facet{Normal: normals[i], Vertex1: v[0], Vertex2: v[2], Vertex3: v[3]})
+	}
+	return facets
+}
+
+func TestMeshFromBox(t *testing.T) {
+	m := NewMesh(box(Vector{0, 0, 0}, Vector{1, 1, 1}), 0)
+
+	if len(m.Vertices) != 8 {
+		t.Errorf("Expected %v vertices, got %v", 8, len(m.Vertices))
+	}
+	if len(m.Triangles) != 12 {
+		t.Errorf("Expected %v triangles, got %v", 12, len(m.Triangles))
+	}
+	if len(m.Edges()) != 18 {
+		t.Errorf("Expected %v edges, got %v", 18, len(m.Edges()))
+	}
+	for _, e := range m.Edges() {
+		if n := len(m.EdgeTriangles(e)); n != 2 {
+			t.Errorf("Edge %v is shared by %v triangles", e, n)
+		}
+	}
+	if n := m.Neighbors(0); len(n) != 3 {
+		t.Errorf("Expected %v neighbors, got %v", 3, n)
+	}
+	for i, f := range box(Vector{0, 0, 0}, Vector{1, 1, 1}) {
+		if m.Facet(i) != f {
+			t.Errorf("Facet %v: expected %v, got %v", i, f, m.Facet(i))
+		}
+	}
+}
+
+func TestMeshWelding(t *testing.T) {
+	facets := box(Vector{0, 0, 0}, Vector{1, 1, 1})
+	// nudge the vertices of some facets as exporters rounding errors do
+	facets[3].Vertex1[0] += 1e-5
+	facets[7].Vertex2[2] -= 1e-5
+	facets[10].Vertex3[1] += 1e-5
+
+	if m := NewMesh(facets, 0); len(m.Vertices) != 11 {
+		t.Errorf("Expected %v vertices without welding, got %v", 11, len(m.Vertices))
+	}
+	if m := NewMesh(facets, 1e-4); len(m.Vertices) != 8 {
+		t.Errorf("Expected %v vertices with welding, got %v", 8, len(m.Vertices))
+	}
+}
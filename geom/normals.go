@@ -0,0 +1,52 @@
+package geom
+
+import (
+	"math"
+)
+
+// NormalTolerance is the largest angle, in radians, between a stored normal
+// and the one computed from the vertex order for the two to agree.
+const NormalTolerance = 0.1
+
+// ComputeNormal returns the unit normal of the facet computed from the
+// order of its vertices, which are counter-clockwise when seen from the
+// side the normal points to. Degenerate facets have a zero normal.
+func (f *Facet) ComputeNormal() Vector {
+	return normal(f.Vertex1, f.Vertex2, f.Vertex3)
+}
+
+// NormalAgrees returns true if the stored normal of the facet points
+// within tolerance radians of the one computed from its vertex order.
+// Missing (zero) normals never agree, while degenerate facets, which have
+// no meaningful normal, always do.
+func (f *Facet) NormalAgrees(tolerance float64) bool {
+	computed := f.ComputeNormal()
+	if computed == (Vector{}) {
+		return true
+	}
+	stored := f.Normal.Normalize()
+	if stored == (Vector{}) {
+		return false
+	}
+	return float64(stored.Dot(computed)) >= math.Cos(tolerance)
+}
+
+// CheckNormals returns the indices of the facets whose normal disagrees
+// with their vertex order.
+func CheckNormals(facets []Facet, tolerance float64) []int {
+	var wrong []int
+	for i := range facets {
+		if !facets[i].NormalAgrees(tolerance) {
+			wrong = append(wrong, i)
+		}
+	}
+	return wrong
+}
+
+// RecomputeNormals replaces the normal of every facet with the one
+// computed from its vertex order.
+func RecomputeNormals(facets []Facet) {
+	for i := range facets {
+		facets[i].Normal = facets[i].ComputeNormal()
+	}
+}
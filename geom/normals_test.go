@@ -0,0 +1,55 @@
+package geom
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComputeNormal(t *testing.T) {
+	for _, f := range box(Vector{0, 0, 0}, Vector{3, 2, 1}) {
+		if n := f.ComputeNormal(); n != f.Normal {
+			t.Errorf("Expected normal %v, got %v", f.Normal, n)
+		}
+	}
+
+	f := Facet{Vertex1: Vector{0, 0, 0}, Vertex2: Vector{1, 1, 1}, Vertex3: Vector{2, 2, 2}}
+	if n := f.ComputeNormal(); n != (Vector{}) {
+		t.Errorf("Expected a zero normal for a degenerate facet, got %v", n)
+	}
+}
+
+func TestCheckNormals(t *testing.T) {
+	facets := box(Vector{0, 0, 0}, Vector{1, 1, 1})
+	facets[1].Normal = Vector{}
+	facets[4].Normal = facets[4].Normal.Scale(-1)
+	facets[7].Normal = Vector{0.05, 1, 0}  // a little off
+	facets[9].Normal = Vector{-2, 0.5, 0}  // not unit length and off
+	facets[11].Normal = Vector{2, 0, 0.01} // not unit length
+
+	if wrong := CheckNormals(facets, NormalTolerance); !reflect.DeepEqual(wrong, []int{1, 4, 9}) {
+		t.Errorf("Expected facets %v to have wrong normals, got %v", []int{1, 4, 9}, wrong)
+	}
+
+	RecomputeNormals(facets)
+	if wrong := CheckNormals(facets, 0); len(wrong) != 0 {
+		t.Errorf("Expected no wrong normals, got %v", wrong)
+	}
+}
+
+func TestAnalyzeNormals(t *testing.T) {
+	facets := box(Vector{0, 0, 0}, Vector{1, 1, 1})
+	facets[1].Normal = Vector{}
+	facets[4].Normal = facets[4].Normal.Scale(-1)
+
+	r := Analyze(NewMesh(facets, 0))
+	if !reflect.DeepEqual(r.MissingNormalFacets, []int{1}) {
+		t.Errorf("Expected facet 1 to miss its normal, got %v", r.MissingNormalFacets)
+	}
+	if !reflect.DeepEqual(r.WrongNormalFacets, []int{4}) {
+		t.Errorf("Expected facet 4 to have a wrong normal, got %v", r.WrongNormalFacets)
+	}
+	// the geometry is fine, whatever the stored normals
+	if !r.Printable() {
+		t.Errorf("Expected a printable mesh, got %+v", r)
+	}
+}
@@ -0,0 +1,200 @@
+package geom
+
+import (
+	"log/slog"
+	"math"
+)
+
+// JoinType is how offset edges are joined around convex corners.
+type JoinType int
+
+const (
+	// MiterJoin extends the offset edges until they meet, squaring off
+	// corners sharper than allowed by the miter limit.
+	MiterJoin JoinType = iota
+	// RoundJoin joins the offset edges with an arc.
+	RoundJoin
+	// SquareJoin cuts the corner at the offset distance from it.
+	SquareJoin
+)
+
+// String returns the name of the join type.
+func (j JoinType) String() string {
+	switch j {
+	case MiterJoin:
+		return "miter"
+	case RoundJoin:
+		return "round"
+	case SquareJoin:
+		return "square"
+	}
+	return "unknown"
+}
+
+// OffsetOptions controls the shape of offset polygons.
+type OffsetOptions struct {
+	Join JoinType
+	// MiterLimit is how far, in multiples of the offset distance, a
+	// mitered corner may extend from the original one.
+	MiterLimit float64
+	// ArcTolerance is the largest distance allowed between a round join
+	// and the true arc.
+	ArcTolerance float64
+	// Logger receives the debug traces of the offsetting, if set.
+	Logger *slog.Logger
+}
+
+// DefaultOffsetOptions are the options used for perimeters.
+var DefaultOffsetOptions = OffsetOptions{
+	Join:         MiterJoin,
+	MiterLimit:   2,
+	ArcTolerance: 0.01,
+}
+
+// Offset grows the polygons by delta, or shrinks them when delta is
+// negative. Holes shrink as the polygons grow and the other way round.
+// Polygons growing into each other are merged, and parts narrower than
+// twice the shrinking distance disappear, possibly splitting polygons.
+func Offset(polygons []Polygon, delta float64, options OffsetOptions) []Polygon {
+	var c clipper
+	for i := range polygons {
+		for _, path := range polygons[i].Paths() {
+			if points := offsetPath(path, delta, &options); len(points) >= 3 {
+				c.addContour(points, 0)
+			}
+		}
+	}
+
+	// The offset contours keep the orientation of the paths they come
+	// from, with the parts that folded over winding the other way, so
+	// the region is where they wind positively.
+	offset := c.execute(func(w0, w1 int) bool { return Positive.inside(w0) })
+	subsystemLogger(options.Logger, "offset").Debug("offset polygons", "polygons", len(polygons), "delta", delta, "offset", len(offset))
+	return offset
+}
+
+// Perimeters returns up to count shells of the given extrusion width
+// inside the polygons, the outermost first. Each shell is the region the
+// center of the extruded line follows, so the first one is half a width
+// inside the polygons, and the next ones a width inside the previous one.
+// There are fewer shells when the polygons are too thin to hold them all.
+func Perimeters(polygons []Polygon, count int, width float64, options OffsetOptions) [][]Polygon {
+	var shells [][]Polygon
+	for i := 0; i < count; i++ {
+		shell := Offset(polygons, -(float64(i)+0.5)*width, options)
+		if len(shell) == 0 {
+			break
+		}
+		shells = append(shells, shell)
+	}
+	return shells
+}
+
+// offsetPath returns the contour of the closed path offset by delta to the
+// right of its edges, on the clipping grid. The contour may cross itself,
+// and is cleaned up by the union done by Offset.
+func offsetPath(path Path, delta float64, options *OffsetOptions) []ipoint {
+	// Drop repeated points, which have no direction.
+	points := make([][2]float64, 0, len(path))
+	for _, p := range path {
+		q := [2]float64{float64(p[0]), float64(p[1])}
+		if len(points) == 0 || q != points[len(points)-1] {
+			points = append(points, q)
+		}
+	}
+	for len(points) > 1 && points[0] == points[len(points)-1] {
+		points = points[:len(points)-1]
+	}
+	n := len(points)
+	if n < 3 {
+		return nil
+	}
+
+	// unit directions of the edges and their right hand normals
+	directions := make([][2]float64, n)
+	normals := make([][2]float64, n)
+	for i := range points {
+		a, b := points[i], points[(i+1)%n]
+		dx, dy := b[0]-a[0], b[1]-a[1]
+		length := math.Hypot(dx, dy)
+		directions[i] = [2]float64{dx / length, dy / length}
+		normals[i] = [2]float64{dy / length, -dx / length}
+	}
+
+	var contour []ipoint
+	add := func(x, y float64) {
+		p := toIPoint(x, y)
+		if len(contour) == 0 || contour[len(contour)-1] != p {
+			contour = append(contour, p)
+		}
+	}
+
+	for i, p := range points {
+		prev := (i + n - 1) % n
+		n1, n2 := normals[prev], normals[i]
+		d1, d2 := directions[prev], directions[i]
+		sin := n1[0]*n2[1] - n1[1]*n2[0]
+		cos := n1[0]*n2[0] + n1[1]*n2[1]
+
+		if delta == 0 || math.Abs(sin) < 1e-12 && cos > 0 {
+			// straight on
+			add(p[0]+n1[0]*delta, p[1]+n1[1]*delta)
+			continue
+		}
+
+		if sin*delta < 0 {
+			// The offset edges overlap: go back through the corner itself,
+			// so that the overlap winds the other way and gets removed.
+			add(p[0]+n1[0]*delta, p[1]+n1[1]*delta)
+			add(p[0], p[1])
+			add(p[0]+n2[0]*delta, p[1]+n2[1]*delta)
+			continue
+		}
+
+		join := options.Join
+		if join == MiterJoin {
+			limit := math.Max(options.MiterLimit, 1)
+			if 1+cos < 2/(limit*limit) {
+				join = SquareJoin
+			}
+		}
+
+		switch join {
+		case MiterJoin:
+			q := delta / (1 + cos)
+			add(p[0]+(n1[0]+n2[0])*q, p[1]+(n1[1]+n2[1])*q)
+
+		case SquareJoin:
+			// Cut the corner with the line at distance delta from it,
+			// square to the bisector of the normals.
+			bx, by := n1[0]+n2[0], n1[1]+n2[1]
+			if length := math.Hypot(bx, by); length > 1e-12 {
+				bx, by = bx/length, by/length
+			} else {
+				// turning back: the corner is ahead
+				bx, by = math.Copysign(1, delta)*d1[0], math.Copysign(1, delta)*d1[1]
+			}
+			dot := n1[0]*bx + n1[1]*by
+			s1 := delta * (1 - dot) / (d1[0]*bx + d1[1]*by)
+			s2 := delta * (1 - dot) / (d2[0]*bx + d2[1]*by)
+			add(p[0]+n1[0]*delta+d1[0]*s1, p[1]+n1[1]*delta+d1[1]*s1)
+			add(p[0]+n2[0]*delta+d2[0]*s2, p[1]+n2[1]*delta+d2[1]*s2)
+
+		case RoundJoin:
+			from := math.Atan2(n1[1], n1[0])
+			angle := math.Atan2(sin, cos)
+			tolerance := math.Min(math.Max(options.ArcTolerance, 1e-4), math.Abs(delta)/2)
+			step := 2 * math.Acos(1-tolerance/math.Abs(delta))
+			steps := int(math.Ceil(math.Abs(angle) / step))
+			for k := 0; k <= steps; k++ {
+				a := from + angle*float64(k)/float64(steps)
+				add(p[0]+math.Cos(a)*delta, p[1]+math.Sin(a)*delta)
+			}
+		}
+	}
+
+	for len(contour) > 1 && contour[0] == contour[len(contour)-1] {
+		contour = contour[:len(contour)-1]
+	}
+	return contour
+}
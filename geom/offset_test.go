@@ -0,0 +1,120 @@
+package geom
+
+import (
+	"math"
+	"testing"
+)
+
+// polygons nests paths into polygons.
+func polygons(paths ...Path) []Polygon {
+	return Nest(paths)
+}
+
+func TestOffsetJoins(t *testing.T) {
+	cases := []struct {
+		join JoinType
+		area float64
+	}{
+		{MiterJoin, 36},
+		{SquareJoin, 36 - 4*(3-2*math.Sqrt2)},
+		{RoundJoin, 16 + 16 + math.Pi},
+	}
+
+	for _, c := range cases {
+		options := DefaultOffsetOptions
+		options.Join = c.join
+		grown := Offset(polygons(square(0, 0, 4)), 1, options)
+		if len(grown) != 1 || len(grown[0].Holes) != 0 {
+			t.Fatalf("%v: expected a single polygon, got %+v", c.join, grown)
+		}
+		if area := Area(grown); math.Abs(area-c.area) > 0.05 {
+			t.Errorf("%v: expected area %v, got %v", c.join, c.area, area)
+		}
+		if b := grown[0].Bounds(); math.Abs(float64(b.Min[0]+1)) > 1e-3 || math.Abs(float64(b.Max[1]-5)) > 1e-3 {
+			t.Errorf("%v: unexpected bounds %v", c.join, b)
+		}
+	}
+}
+
+func TestOffsetMiterLimit(t *testing.T) {
+	// a thin spike gets squared off instead of extending far away
+	spike := Path{{0, 0}, {10, 0}, {0, 1}}
+	grown := Offset(polygons(spike), 1, DefaultOffsetOptions)
+	if len(grown) != 1 {
+		t.Fatalf("Expected a single polygon, got %+v", grown)
+	}
+	if b := grown[0].Bounds(); b.Max[0] > 12.1 {
+		t.Errorf("Spike extends to %v", b.Max[0])
+	}
+}
+
+func TestOffsetHoles(t *testing.T) {
+	frame := polygons(square(0, 0, 10), square(3, 3, 4))
+
+	shrunk := Offset(frame, -1, DefaultOffsetOptions)
+	if len(shrunk) != 1 || len(shrunk[0].Holes) != 1 {
+		t.Fatalf("Expected a polygon with a hole, got %+v", shrunk)
+	}
+	if area := Area(shrunk); math.Abs(area-(64-36)) > 1e-3 {
+		t.Errorf("Expected area %v, got %v", 64-36, area)
+	}
+	if shrunk[0].Outer.Area() <= 0 || shrunk[0].Holes[0].Area() >= 0 {
+		t.Error("Wrong orientation")
+	}
+
+	// growing enough fills the hole
+	grown := Offset(frame, 2.5, DefaultOffsetOptions)
+	if len(grown) != 1 || len(grown[0].Holes) != 0 {
+		t.Errorf("Expected a polygon without holes, got %+v", grown)
+	}
+}
+
+func TestOffsetConcave(t *testing.T) {
+	// an L shape, shrinking through its inner corner
+	l := Path{{0, 0}, {4, 0}, {4, 2}, {2, 2}, {2, 4}, {0, 4}}
+	shrunk := Offset(polygons(l), -0.5, DefaultOffsetOptions)
+	if len(shrunk) != 1 {
+		t.Fatalf("Expected a single polygon, got %+v", shrunk)
+	}
+	if area := Area(shrunk); math.Abs(area-5) > 1e-3 {
+		t.Errorf("Expected area %v, got %v", 5, area)
+	}
+}
+
+func TestOffsetTopology(t *testing.T) {
+	// a dumbbell whose handle disappears when shrinking
+	dumbbell := Path{{0, 0}, {4, 0}, {4, 1.5}, {6, 1.5}, {6, 0}, {10, 0}, {10, 4}, {6, 4}, {6, 2.5}, {4, 2.5}, {4, 4}, {0, 4}}
+	if split := Offset(polygons(dumbbell), -0.75, DefaultOffsetOptions); len(split) != 2 {
+		t.Errorf("Expected the dumbbell to split in %v, got %v", 2, len(split))
+	}
+
+	// squares growing into each other merge
+	pair := polygons(square(0, 0, 2), square(3, 0, 2))
+	if merged := Offset(pair, 1, DefaultOffsetOptions); len(merged) != 1 {
+		t.Errorf("Expected the squares to merge, got %v polygons", len(merged))
+	}
+
+	// a square shrinking by more than half its size vanishes
+	if gone := Offset(polygons(square(0, 0, 2)), -1.5, DefaultOffsetOptions); len(gone) != 0 {
+		t.Errorf("Expected nothing left, got %+v", gone)
+	}
+}
+
+func TestPerimeters(t *testing.T) {
+	shells := Perimeters(polygons(square(0, 0, 10)), 3, 0.5, DefaultOffsetOptions)
+	if len(shells) != 3 {
+		t.Fatalf("Expected %v shells, got %v", 3, len(shells))
+	}
+	for i, shell := range shells {
+		size := 10 - float64(2*i+1)*0.5
+		if area := Area(shell); math.Abs(area-size*size) > 1e-3 {
+			t.Errorf("Shell %v: expected area %v, got %v", i, size*size, area)
+		}
+	}
+
+	// a strip too narrow for more than two shells
+	strip := polygons(Path{{0, 0}, {10, 0}, {10, 1.8}, {0, 1.8}})
+	if shells := Perimeters(strip, 3, 0.5, DefaultOffsetOptions); len(shells) != 2 {
+		t.Errorf("Expected %v shells, got %v", 2, len(shells))
+	}
+}
@@ -0,0 +1,149 @@
+package geom
+
+import (
+	"context"
+	"sort"
+)
+
+// LayerSegments are the segments obtained slicing a layer, in the order of
+// the facets they come from.
+type LayerSegments struct {
+	Index    int32
+	Z        float64
+	Segments []Segment
+}
+
+// layerJob is the slicing of a single layer by one of the workers.
+type layerJob struct {
+	index  int32
+	facets []int // indices of the facets that may cross the layer, sorted
+	done   chan []Segment
+}
+
+// SliceParallel slices the facets like a Slicer does, but spreads the
+// layers over a pool of workers. The layers with at least a segment are
+// sent over the returned channel in increasing order, and are identical to
+// the ones produced by Slicer.Layers.
+//
+// The facets are swept by height, so that each worker only looks at the
+// facets crossing its layer. Only a few layers ahead of the one being
+// consumed are sliced at any time.
+//
+// Consumers stopping before the end must cancel the context, which stops
+// the workers and closes the channel.
+func SliceParallel(ctx context.Context, facets []Facet, layerHeight float64, workers int) <-chan LayerSegments {
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan *layerJob)
+	pending := make(chan *layerJob, 2*workers)
+	out := make(chan LayerSegments)
+
+	// Compute the normals and layer ranges once for all the layers.
+	normals := make([]Vector, len(facets))
+	minLayers := make([]int32, len(facets))
+	maxLayers := make([]int32, len(facets))
+	order := make([]int, len(facets))
+	for i := range facets {
+		normals[i] = facets[i].ComputeNormal()
+		minLayers[i], maxLayers[i] = layerRange(&facets[i], layerHeight)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return minLayers[order[i]] < minLayers[order[j]] })
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for job := range jobs {
+				if ctx.Err() != nil {
+					job.done <- nil
+					continue
+				}
+				z := float64(job.index) * layerHeight
+				var segments []Segment
+				for _, i := range job.facets {
+					segment, ok := SliceFacet(&facets[i], z)
+					if !ok || segment.Start == segment.End {
+						continue
+					}
+					segment.Normal = &normals[i]
+					segments = append(segments, segment)
+				}
+				job.done <- segments
+			}
+		}()
+	}
+
+	// Sweep the layers from the bottom up, keeping track of the facets
+	// that span each of them.
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		if len(order) == 0 {
+			return
+		}
+
+		maxLayer := maxLayers[0]
+		for _, m := range maxLayers {
+			if m > maxLayer {
+				maxLayer = m
+			}
+		}
+
+		var active []int
+		next := 0
+		for layer := minLayers[order[0]]; layer <= maxLayer; layer++ {
+			for next < len(order) && minLayers[order[next]] <= layer {
+				active = append(active, order[next])
+				next++
+			}
+			kept := active[:0]
+			for _, i := range active {
+				if maxLayers[i] >= layer {
+					kept = append(kept, i)
+				}
+			}
+			active = kept
+			if len(active) == 0 {
+				continue
+			}
+
+			spanning := append([]int(nil), active...)
+			sort.Ints(spanning)
+			job := &layerJob{index: layer, facets: spanning, done: make(chan []Segment, 1)}
+			select {
+			case pending <- job:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Collect the results in order.
+	go func() {
+		defer close(out)
+		for job := range pending {
+			var segments []Segment
+			select {
+			case segments = <-job.done:
+			case <-ctx.Done():
+				return
+			}
+			if len(segments) == 0 {
+				continue
+			}
+			select {
+			case out <- LayerSegments{Index: job.index, Z: float64(job.index) * layerHeight, Segments: segments}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,92 @@
+package geom
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// sameLayers returns true if the layers have the same segments, with the
+// same normals.
+func sameLayers(a, b []LayerSegments) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Index != b[i].Index || a[i].Z != b[i].Z || len(a[i].Segments) != len(b[i].Segments) {
+			return false
+		}
+		for j := range a[i].Segments {
+			sa, sb := a[i].Segments[j], b[i].Segments[j]
+			if *sa.Normal != *sb.Normal {
+				return false
+			}
+			sa.Normal, sb.Normal = nil, nil
+			if !reflect.DeepEqual(sa, sb) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestSliceParallelMatchesSerial(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+
+	var facets []Facet
+	for i := 0; i < 20; i++ {
+		// a stack of shapes, some overlapping and some apart
+		prismFacets := prism(r, randomHeights(r, 0.2))
+		offset := Vector{float32(r.Intn(5)) * 3, 0, float32(r.Intn(10)) * 0.5}
+		for _, f := range prismFacets {
+			f.Vertex1, f.Vertex2, f.Vertex3 = f.Vertex1.Add(offset), f.Vertex2.Add(offset), f.Vertex3.Add(offset)
+			facets = append(facets, f)
+		}
+	}
+
+	s := NewSlicer(0.2)
+	for _, f := range facets {
+		s.AddFacet(f)
+	}
+	serial := s.Layers()
+	if len(serial) == 0 {
+		t.Fatal("Expected some layers")
+	}
+
+	for _, workers := range []int{0, 1, 2, 3, 8} {
+		var parallel []LayerSegments
+		for l := range SliceParallel(context.Background(), facets, 0.2, workers) {
+			parallel = append(parallel, l)
+		}
+		if !sameLayers(serial, parallel) {
+			t.Errorf("%v workers: layers differ from the serial ones", workers)
+		}
+	}
+}
+
+func TestSliceParallelEmpty(t *testing.T) {
+	for range SliceParallel(context.Background(), nil, 0.2, 4) {
+		t.Error("Expected no layers")
+	}
+}
+
+func TestSliceParallelCancel(t *testing.T) {
+	facets := box(Vector{0, 0, 0}, Vector{1, 1, 20})
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	layers := SliceParallel(ctx, facets, 0.1, 4)
+	<-layers // stop reading after the first layer
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("Expected the %v goroutines of before, got %v", before, n)
+	}
+}
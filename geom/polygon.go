@@ -0,0 +1,270 @@
+package geom
+
+import (
+	"math"
+	"sort"
+)
+
+// Bounds is an axis aligned bounding box.
+type Bounds struct {
+	Min Point
+	Max Point
+}
+
+// emptyBounds contains nothing, and grows to fit whatever is added to it.
+var emptyBounds = Bounds{
+	Min: Point{math.MaxFloat32, math.MaxFloat32},
+	Max: Point{-math.MaxFloat32, -math.MaxFloat32},
+}
+
+// Empty returns true if the bounds contain no point at all.
+func (b Bounds) Empty() bool {
+	return b.Min[0] > b.Max[0] || b.Min[1] > b.Max[1]
+}
+
+// Union returns the bounds containing both b and c.
+func (b Bounds) Union(c Bounds) Bounds {
+	return Bounds{
+		Min: Point{float32(math.Min(float64(b.Min[0]), float64(c.Min[0]))), float32(math.Min(float64(b.Min[1]), float64(c.Min[1])))},
+		Max: Point{float32(math.Max(float64(b.Max[0]), float64(c.Max[0]))), float32(math.Max(float64(b.Max[1]), float64(c.Max[1])))},
+	}
+}
+
+// Overlaps returns true if the bounds have points in common.
+func (b Bounds) Overlaps(c Bounds) bool {
+	return b.Min[0] <= c.Max[0] && c.Min[0] <= b.Max[0] && b.Min[1] <= c.Max[1] && c.Min[1] <= b.Max[1]
+}
+
+// Area returns the area enclosed by the closed path, positive if it goes
+// around counter-clockwise and negative otherwise.
+func (p Path) Area() float64 {
+	area := 0.0
+	for i := range p {
+		a, b := p[i], p[(i+1)%len(p)]
+		area += float64(a[0])*float64(b[1]) - float64(b[0])*float64(a[1])
+	}
+	return area / 2
+}
+
+// Reverse returns a copy of the path going the other way around.
+func (p Path) Reverse() Path {
+	reversed := make(Path, len(p))
+	for i, point := range p {
+		reversed[len(p)-1-i] = point
+	}
+	return reversed
+}
+
+// Bounds returns the bounding box of the path.
+func (p Path) Bounds() Bounds {
+	b := emptyBounds
+	for _, point := range p {
+		b = b.Union(Bounds{point, point})
+	}
+	return b
+}
+
+// Contains returns true if the point is inside the closed path. Points on
+// the path itself may be considered either inside or outside.
+func (p Path) Contains(point Point) bool {
+	x, y := float64(point[0]), float64(point[1])
+	inside := false
+	for i := range p {
+		a, b := p[i], p[(i+1)%len(p)]
+		ax, ay := float64(a[0]), float64(a[1])
+		bx, by := float64(b[0]), float64(b[1])
+		if (ay > y) != (by > y) && x < ax+(y-ay)*(bx-ax)/(by-ay) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// ContainsPath returns true if the path q, which must not cross p, is
+// inside p. Points of q lying on p are ignored, as long as one is not.
+func (p Path) ContainsPath(q Path) bool {
+	bounds := p.Bounds()
+	for _, point := range q {
+		if point[0] < bounds.Min[0] || point[0] > bounds.Max[0] || point[1] < bounds.Min[1] || point[1] > bounds.Max[1] {
+			return false
+		}
+	}
+
+	for _, point := range q {
+		if !p.onPath(point) {
+			return p.Contains(point)
+		}
+	}
+
+	// All of q lies on p: fall back to a point just inside of q.
+	for i := range q {
+		a, b := q[i], q[(i+1)%len(q)]
+		mid := Point{(a[0] + b[0]) / 2, (a[1] + b[1]) / 2}
+		if !p.onPath(mid) {
+			return p.Contains(mid)
+		}
+	}
+	return false
+}
+
+// onPath returns true if the point lies on one of the edges of the path.
+func (p Path) onPath(point Point) bool {
+	x, y := float64(point[0]), float64(point[1])
+	for i := range p {
+		a, b := p[i], p[(i+1)%len(p)]
+		ax, ay := float64(a[0]), float64(a[1])
+		bx, by := float64(b[0]), float64(b[1])
+		if (bx-ax)*(y-ay)-(by-ay)*(x-ax) != 0 {
+			continue
+		}
+		if x >= math.Min(ax, bx) && x <= math.Max(ax, bx) && y >= math.Min(ay, by) && y <= math.Max(ay, by) {
+			return true
+		}
+	}
+	return false
+}
+
+// Polygon is a region bounded by an outer path going around
+// counter-clockwise, minus the holes bounded by paths going around
+// clockwise.
+type Polygon struct {
+	Outer Path
+	Holes []Path
+}
+
+// Area returns the area of the polygon, not counting its holes.
+func (p *Polygon) Area() float64 {
+	area := math.Abs(p.Outer.Area())
+	for _, hole := range p.Holes {
+		area -= math.Abs(hole.Area())
+	}
+	return area
+}
+
+// Bounds returns the bounding box of the polygon.
+func (p *Polygon) Bounds() Bounds {
+	return p.Outer.Bounds()
+}
+
+// Contains returns true if the point is inside the polygon and outside of
+// all its holes.
+func (p *Polygon) Contains(point Point) bool {
+	if !p.Outer.Contains(point) {
+		return false
+	}
+	for _, hole := range p.Holes {
+		if hole.Contains(point) {
+			return false
+		}
+	}
+	return true
+}
+
+// Paths returns the outer path of the polygon followed by its holes.
+func (p *Polygon) Paths() []Path {
+	return append([]Path{p.Outer}, p.Holes...)
+}
+
+// Layer is the cross-section of a model at a given height, made of
+// separate islands.
+type Layer struct {
+	Index   int32
+	Z       float64
+	Islands []Polygon
+}
+
+// NewLayer builds a layer from closed paths, nesting them into islands
+// with Nest.
+func NewLayer(index int32, z float64, paths []Path) *Layer {
+	return &Layer{Index: index, Z: z, Islands: Nest(paths)}
+}
+
+// Nest builds polygons from closed paths, nesting them by containment:
+// paths inside an even number of others are the outer boundaries of
+// polygons, the others holes in the polygon they are directly inside of.
+// Paths are rewound as needed, so the orientation they come with doesn't
+// matter, and paths enclosing no area are dropped.
+func Nest(paths []Path) []Polygon {
+	type loop struct {
+		path    Path
+		area    float64
+		parent  int
+		depth   int
+		polygon int
+	}
+
+	loops := make([]*loop, 0, len(paths))
+	for _, path := range paths {
+		if area := math.Abs(path.Area()); len(path) >= 3 && area > 0 {
+			loops = append(loops, &loop{path: path, area: area, parent: -1})
+		}
+	}
+
+	// Any loop containing another is larger than it, so looking at them
+	// from the largest to the smallest finds the parents before the
+	// children, and the first parent found going backwards is the
+	// innermost one.
+	sort.SliceStable(loops, func(i, j int) bool { return loops[i].area > loops[j].area })
+
+	var polygons []Polygon
+	for i, child := range loops {
+		for j := i - 1; j >= 0; j-- {
+			if loops[j].path.ContainsPath(child.path) {
+				child.parent = j
+				child.depth = loops[j].depth + 1
+				break
+			}
+		}
+
+		if child.depth%2 == 0 {
+			outer := child.path
+			if outer.Area() < 0 {
+				outer = outer.Reverse()
+			}
+			child.polygon = len(polygons)
+			polygons = append(polygons, Polygon{Outer: outer})
+		} else {
+			hole := child.path
+			if hole.Area() > 0 {
+				hole = hole.Reverse()
+			}
+			polygon := &polygons[loops[child.parent].polygon]
+			polygon.Holes = append(polygon.Holes, hole)
+		}
+	}
+
+	return polygons
+}
+
+// Area returns the total area of the islands of the layer.
+func (l *Layer) Area() float64 {
+	return Area(l.Islands)
+}
+
+// Area returns the total area of the polygons.
+func Area(polygons []Polygon) float64 {
+	area := 0.0
+	for i := range polygons {
+		area += polygons[i].Area()
+	}
+	return area
+}
+
+// Bounds returns the bounding box of the layer.
+func (l *Layer) Bounds() Bounds {
+	b := emptyBounds
+	for i := range l.Islands {
+		b = b.Union(l.Islands[i].Bounds())
+	}
+	return b
+}
+
+// Contains returns true if the point is inside one of the islands.
+func (l *Layer) Contains(point Point) bool {
+	for i := range l.Islands {
+		if l.Islands[i].Contains(point) {
+			return true
+		}
+	}
+	return false
+}
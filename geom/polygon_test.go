@@ -0,0 +1,93 @@
+package geom
+
+import (
+	"testing"
+)
+
+// square returns a counter-clockwise square path.
+func square(x, y, size float32) Path {
+	return Path{{x, y}, {x + size, y}, {x + size, y + size}, {x, y + size}}
+}
+
+func TestPathQueries(t *testing.T) {
+	p := square(1, 2, 3)
+
+	if area := p.Area(); area != 9 {
+		t.Errorf("Expected area %v, got %v", 9, area)
+	}
+	if area := p.Reverse().Area(); area != -9 {
+		t.Errorf("Expected area %v, got %v", -9, area)
+	}
+	if b := p.Bounds(); b.Min != (Point{1, 2}) || b.Max != (Point{4, 5}) {
+		t.Errorf("Unexpected bounds %v", b)
+	}
+	if !p.Contains(Point{2, 3}) || p.Contains(Point{0, 3}) || p.Contains(Point{2, 6}) {
+		t.Error("Wrong containment")
+	}
+}
+
+func TestNewLayerNesting(t *testing.T) {
+	// two islands: a square with a hole containing another island, and a
+	// lone square, with paths in the wrong orientation and random order
+	paths := []Path{
+		square(3, 3, 4),             // island in the hole
+		square(1, 1, 8).Reverse(),   // outer boundary
+		square(20, 0, 1).Reverse(),  // lone island
+		square(2, 2, 6),             // hole
+		square(4, 4, 1).Reverse(),   // hole in the island in the hole
+		{{30, 0}, {31, 0}, {32, 0}}, // no area
+	}
+
+	l := NewLayer(3, 0.6, paths)
+
+	if l.Index != 3 || l.Z != 0.6 {
+		t.Errorf("Unexpected layer %v at %v", l.Index, l.Z)
+	}
+	if len(l.Islands) != 3 {
+		t.Fatalf("Expected %v islands, got %v", 3, len(l.Islands))
+	}
+
+	expected := []struct {
+		area  float64
+		holes int
+	}{{28, 1}, {15, 1}, {1, 0}}
+	for i, e := range expected {
+		island := l.Islands[i]
+		if island.Area() != e.area || len(island.Holes) != e.holes {
+			t.Errorf("Island %v: expected area %v with %v holes, got %v with %v", i, e.area, e.holes, island.Area(), len(island.Holes))
+		}
+		if island.Outer.Area() <= 0 {
+			t.Errorf("Island %v: outer path is clockwise", i)
+		}
+		for _, hole := range island.Holes {
+			if hole.Area() >= 0 {
+				t.Errorf("Island %v: hole is counter-clockwise", i)
+			}
+		}
+	}
+
+	if l.Area() != 44 {
+		t.Errorf("Expected area %v, got %v", 44, l.Area())
+	}
+	if b := l.Bounds(); b.Min != (Point{1, 0}) || b.Max != (Point{21, 9}) {
+		t.Errorf("Unexpected bounds %v", b)
+	}
+	for _, c := range []struct {
+		point  Point
+		inside bool
+	}{{Point{1.5, 1.5}, true}, {Point{2.5, 2.5}, false}, {Point{3.5, 3.5}, true}, {Point{4.5, 4.5}, false}, {Point{20.5, 0.5}, true}, {Point{10, 10}, false}} {
+		if l.Contains(c.point) != c.inside {
+			t.Errorf("Expected containment of %v to be %v", c.point, c.inside)
+		}
+	}
+}
+
+func TestNewLayerTouchingPaths(t *testing.T) {
+	// a hole sharing a corner with its outer boundary
+	paths := []Path{square(0, 0, 4), Path{{0, 0}, {2, 1}, {1, 2}}.Reverse()}
+
+	l := NewLayer(0, 0, paths)
+	if len(l.Islands) != 1 || len(l.Islands[0].Holes) != 1 {
+		t.Errorf("Expected an island with a hole, got %+v", l.Islands)
+	}
+}
@@ -0,0 +1,256 @@
+package geom
+
+import (
+	"log/slog"
+)
+
+// RepairOptions control how Repair fixes a mesh.
+type RepairOptions struct {
+	// Tolerance is the distance within which vertices are welded.
+	Tolerance float32
+
+	// MaxHoleEdges is the number of edges of the largest hole that gets
+	// closed. Larger holes are likely missing parts of the model rather
+	// than defects, so they are left open. Zero disables hole filling.
+	MaxHoleEdges int
+
+	// Logger receives the debug traces of the repair, if set.
+	Logger *slog.Logger
+}
+
+// DefaultRepairOptions are suitable for the output of most exporters and
+// scanners, with coordinates in millimeters.
+var DefaultRepairOptions = RepairOptions{
+	Tolerance:    1e-5,
+	MaxHoleEdges: 32,
+}
+
+// RepairResult describes what Repair did.
+type RepairResult struct {
+	DegenerateRemoved int // degenerate facets removed
+	DuplicatesRemoved int // duplicate facets removed
+	Flipped           int // facets whose winding was reversed
+	HolesFilled       int // holes closed
+	FacetsAdded       int // facets added to close the holes
+}
+
+// Repair fixes the most common defects of the facets: it removes
+// degenerate and duplicate facets, closes small holes, makes the winding of
+// every facet consistent with its neighbors and outward facing, and
+// recomputes all the normals from the vertex order.
+//
+// Facets keep their relative order, with the ones closing holes appended
+// at the end.
+func Repair(facets []Facet, options RepairOptions) ([]Facet, *RepairResult) {
+	result := new(RepairResult)
+
+	// Drop the facets without area and the repeated ones.
+	m := NewMesh(facets, options.Tolerance)
+	report := Analyze(m)
+	drop := make(map[int]bool)
+	for _, i := range report.DegenerateFacets {
+		drop[i] = true
+		result.DegenerateRemoved++
+	}
+	for _, i := range report.DuplicateFacets {
+		if !drop[i] {
+			drop[i] = true
+			result.DuplicatesRemoved++
+		}
+	}
+
+	kept := make([]Facet, 0, len(facets))
+	for i := range facets {
+		if !drop[i] {
+			f := m.Facet(i)
+			f.Attribute = facets[i].Attribute
+			kept = append(kept, f)
+		}
+	}
+
+	// The vertices have been welded already, so exact matching suffices.
+	m = NewMesh(kept, 0)
+	result.Flipped = m.unifyWinding()
+	if options.MaxHoleEdges > 0 {
+		result.HolesFilled, result.FacetsAdded = m.fillHoles(options.MaxHoleEdges)
+	}
+
+	subsystemLogger(options.Logger, "repair").Debug("repaired mesh",
+		"degenerate", result.DegenerateRemoved, "duplicates", result.DuplicatesRemoved,
+		"flipped", result.Flipped, "holes", result.HolesFilled)
+
+	repaired := make([]Facet, len(m.Triangles))
+	for i := range m.Triangles {
+		repaired[i] = m.Facet(i)
+		repaired[i].Normal = repaired[i].ComputeNormal()
+		if i < len(kept) {
+			repaired[i].Attribute = kept[i].Attribute
+		}
+	}
+
+	return repaired, result
+}
+
+// unifyWinding flips triangles so that each traverses its shared edges in
+// the opposite direction of its neighbors, then flips whole connected
+// components enclosing a negative volume so they face outwards. It returns
+// the number of triangles flipped.
+func (m *Mesh) unifyWinding() int {
+	flipped := make([]bool, len(m.Triangles))
+	visited := make([]bool, len(m.Triangles))
+
+	for start := range m.Triangles {
+		if visited[start] {
+			continue
+		}
+
+		// Orient the component reachable through manifold edges.
+		component := []int{start}
+		visited[start] = true
+		for k := 0; k < len(component); k++ {
+			i := component[k]
+			t := m.Triangles[i]
+			for j := 0; j < 3; j++ {
+				e := NewEdge(t[j], t[(j+1)%3])
+				triangles := m.EdgeTriangles(e)
+				if len(triangles) != 2 {
+					continue
+				}
+				n := triangles[0]
+				if n == i {
+					n = triangles[1]
+				}
+				if visited[n] {
+					continue
+				}
+				if m.traverses(n, e) == m.traverses(i, e) {
+					m.Triangles[n][1], m.Triangles[n][2] = m.Triangles[n][2], m.Triangles[n][1]
+					flipped[n] = !flipped[n]
+				}
+				visited[n] = true
+				component = append(component, n)
+			}
+		}
+
+		// Turn it inside out if it encloses a negative volume.
+		if m.volume(component) < 0 {
+			for _, i := range component {
+				m.Triangles[i][1], m.Triangles[i][2] = m.Triangles[i][2], m.Triangles[i][1]
+				flipped[i] = !flipped[i]
+			}
+		}
+	}
+
+	count := 0
+	for i, f := range flipped {
+		if f {
+			count++
+			m.Normals[i] = m.Normals[i].Scale(-1)
+		}
+	}
+	return count
+}
+
+// volume returns the signed volume enclosed by the triangles, which is
+// positive when their normals face outwards.
+func (m *Mesh) volume(triangles []int) float64 {
+	volume := 0.0
+	for _, i := range triangles {
+		t := m.Triangles[i]
+		a, b, c := m.Vertices[t[0]], m.Vertices[t[1]], m.Vertices[t[2]]
+		volume += float64(a[0])*(float64(b[1])*float64(c[2])-float64(b[2])*float64(c[1])) +
+			float64(a[1])*(float64(b[2])*float64(c[0])-float64(b[0])*float64(c[2])) +
+			float64(a[2])*(float64(b[0])*float64(c[1])-float64(b[1])*float64(c[0]))
+	}
+	return volume / 6
+}
+
+// fillHoles closes the holes bordered by at most maxEdges edges, fanning
+// triangles out of a new vertex in the middle of each hole. It returns the
+// number of holes closed and of triangles added.
+func (m *Mesh) fillHoles(maxEdges int) (int, int) {
+	// Boundary edges go around the hole in the opposite direction of the
+	// triangle they belong to.
+	next := make(map[int][]int)
+	for _, e := range m.Edges() {
+		triangles := m.EdgeTriangles(e)
+		if len(triangles) != 1 {
+			continue
+		}
+		if m.traverses(triangles[0], e) {
+			next[e[1]] = append(next[e[1]], e[0])
+		} else {
+			next[e[0]] = append(next[e[0]], e[1])
+		}
+	}
+
+	holes, added := 0, 0
+	for _, e := range m.Edges() {
+		triangles := m.EdgeTriangles(e)
+		if len(triangles) != 1 {
+			continue
+		}
+		start := e[1]
+		if !m.traverses(triangles[0], e) {
+			start = e[0]
+		}
+
+		loop := m.boundaryLoop(next, start, maxEdges)
+		if loop == nil {
+			continue
+		}
+
+		holes++
+		if len(loop) == 3 {
+			m.addTriangle([3]int{loop[0], loop[1], loop[2]})
+			added++
+			continue
+		}
+
+		var center Vector
+		for _, v := range loop {
+			center = center.Add(m.Vertices[v])
+		}
+		m.Vertices = append(m.Vertices, center.Scale(1/float32(len(loop))))
+		c := len(m.Vertices) - 1
+		for k := range loop {
+			m.addTriangle([3]int{loop[k], loop[(k+1)%len(loop)], c})
+			added++
+		}
+	}
+
+	return holes, added
+}
+
+// boundaryLoop follows the boundary edges from start until getting back to
+// it, consuming them. It returns nil if the loop doesn't close or is longer
+// than maxEdges, leaving the edges in place.
+func (m *Mesh) boundaryLoop(next map[int][]int, start int, maxEdges int) []int {
+	loop := []int{start}
+	for v := start; ; {
+		if len(next[v]) == 0 || len(loop) > maxEdges {
+			return nil
+		}
+		v = next[v][0]
+		if v == start {
+			break
+		}
+		loop = append(loop, v)
+	}
+
+	for _, v := range loop {
+		next[v] = next[v][1:]
+	}
+	return loop
+}
+
+// addTriangle adds a triangle to the mesh, updating the edge adjacency.
+func (m *Mesh) addTriangle(t [3]int) {
+	i := len(m.Triangles)
+	m.Triangles = append(m.Triangles, t)
+	m.Normals = append(m.Normals, normal(m.Vertices[t[0]], m.Vertices[t[1]], m.Vertices[t[2]]))
+	for j := 0; j < 3; j++ {
+		e := NewEdge(t[j], t[(j+1)%3])
+		m.edges[e] = append(m.edges[e], i)
+	}
+}
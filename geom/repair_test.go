@@ -0,0 +1,81 @@
+package geom
+
+import (
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	facets := box(Vector{0, 0, 0}, Vector{2, 1, 1})
+
+	// remove a triangle, flip another, duplicate one, add a sliver and
+	// mess up some normals
+	facets = append(facets[:3], facets[4:]...)
+	facets[5].Vertex1, facets[5].Vertex2 = facets[5].Vertex2, facets[5].Vertex1
+	facets = append(facets, facets[7],
+		Facet{Vertex1: Vector{0, 0, 0}, Vertex2: Vector{1, 0, 0}, Vertex3: Vector{2, 0, 0}})
+	facets[0].Normal = Vector{}
+	facets[8].Normal = Vector{0, 0, 1}
+
+	repaired, result := Repair(facets, DefaultRepairOptions)
+
+	if result.DegenerateRemoved != 1 || result.DuplicatesRemoved != 1 {
+		t.Errorf("Unexpected removals: %+v", result)
+	}
+	if result.Flipped != 1 {
+		t.Errorf("Expected %v flipped facet, got %+v", 1, result)
+	}
+	if result.HolesFilled != 1 || result.FacetsAdded != 1 {
+		t.Errorf("Expected %v hole filled with %v facet, got %+v", 1, 1, result)
+	}
+	if len(repaired) != 12 {
+		t.Errorf("Expected %v facets, got %v", 12, len(repaired))
+	}
+
+	if r := Analyze(NewMesh(repaired, 0)); !r.Printable() {
+		t.Errorf("Expected a printable mesh, got %+v", r)
+	}
+	for i, f := range repaired {
+		if f.Normal != normal(f.Vertex1, f.Vertex2, f.Vertex3) {
+			t.Errorf("Facet %v has normal %v", i, f.Normal)
+		}
+	}
+	if v := NewMesh(repaired, 0).volume([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}); v != 2 {
+		t.Errorf("Expected a volume of %v, got %v", 2, v)
+	}
+}
+
+func TestRepairInsideOut(t *testing.T) {
+	facets := box(Vector{0, 0, 0}, Vector{1, 1, 1})
+	for i := range facets {
+		facets[i].Vertex1, facets[i].Vertex2 = facets[i].Vertex2, facets[i].Vertex1
+	}
+
+	repaired, result := Repair(facets, DefaultRepairOptions)
+
+	if result.Flipped != 12 {
+		t.Errorf("Expected %v flipped facets, got %+v", 12, result)
+	}
+	if repaired[0].Normal != (Vector{0, 0, -1}) {
+		t.Errorf("Expected the bottom to face down, got %v", repaired[0].Normal)
+	}
+}
+
+func TestRepairFillsLargerHoles(t *testing.T) {
+	facets := box(Vector{0, 0, 0}, Vector{1, 1, 1})
+
+	// remove the whole top, leaving a square hole
+	facets = append(facets[:2], facets[4:]...)
+
+	repaired, result := Repair(facets, DefaultRepairOptions)
+	if result.HolesFilled != 1 || result.FacetsAdded != 4 {
+		t.Errorf("Expected %v hole filled with %v facets, got %+v", 1, 4, result)
+	}
+	if r := Analyze(NewMesh(repaired, 0)); !r.Printable() {
+		t.Errorf("Expected a printable mesh, got %+v", r)
+	}
+
+	repaired, result = Repair(facets, RepairOptions{MaxHoleEdges: 3})
+	if result.HolesFilled != 0 || len(repaired) != 10 {
+		t.Errorf("Expected the hole to be left open, got %+v", result)
+	}
+}
@@ -0,0 +1,166 @@
+package geom
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+// prism returns a closed mesh made of rings of vertices stacked at the
+// given heights, with random radii around the z axis. Ring heights chosen
+// on layer planes exercise the cases of vertices, edges and faces lying on
+// the slicing plane.
+func prism(r *rand.Rand, heights []float32) []Facet {
+	n := 3 + r.Intn(10)
+	angles := make([]float64, n)
+	for i := range angles {
+		angles[i] = 2 * math.Pi * (float64(i) + 0.4*r.Float64()) / float64(n)
+	}
+
+	// Vertices with the same index lie on the same half plane through the
+	// axis, and consecutive ones less than half a turn apart, so the walls
+	// go around the axis without ever intersecting each other.
+	rings := make([][]Vector, len(heights))
+	for k, z := range heights {
+		rings[k] = make([]Vector, n)
+		for i, angle := range angles {
+			radius := 0.5 + 1.5*r.Float64()
+			rings[k][i] = Vector{float32(radius * math.Cos(angle)), float32(radius * math.Sin(angle)), z}
+		}
+	}
+
+	var facets []Facet
+	add := func(a, b, c Vector) {
+		facets = append(facets, Facet{Vertex1: a, Vertex2: b, Vertex3: c})
+	}
+	for k := 0; k+1 < len(rings); k++ {
+		for i := 0; i < n; i++ {
+			j := (i + 1) % n
+			add(rings[k][i], rings[k][j], rings[k+1][j])
+			add(rings[k][i], rings[k+1][j], rings[k+1][i])
+		}
+	}
+	bottom, top := rings[0], rings[len(rings)-1]
+	for i := 1; i+1 < n; i++ {
+		add(bottom[0], bottom[i+1], bottom[i])
+		add(top[0], top[i], top[i+1])
+	}
+
+	// shuffle the facets, since the result should not depend on their order
+	r.Shuffle(len(facets), func(i, j int) { facets[i], facets[j] = facets[j], facets[i] })
+	return facets
+}
+
+// randomHeights returns increasing heights, most of them on layer planes.
+func randomHeights(r *rand.Rand, layerHeight float64) []float32 {
+	heights := make([]float32, 2+r.Intn(4))
+	z := float64(r.Intn(5)-2) * layerHeight
+	for k := range heights {
+		if r.Intn(3) == 0 {
+			z += layerHeight * r.Float64()
+		}
+		heights[k] = float32(z)
+		z += layerHeight * float64(1+r.Intn(4))
+	}
+	return heights
+}
+
+// signedArea returns the area enclosed by the segments, positive when
+// they go around counter-clockwise.
+func signedArea(segments []Segment) float64 {
+	area := 0.0
+	for _, s := range segments {
+		area += float64(s.Start[0])*float64(s.End[1]) - float64(s.End[0])*float64(s.Start[1])
+	}
+	return area / 2
+}
+
+func TestSliceRandomPrisms(t *testing.T) {
+	const layerHeight = 0.25
+
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		heights := randomHeights(r, layerHeight)
+		facets := prism(r, heights)
+		segmentsByLayer, _, _ := FacetsByLayer(&facets, layerHeight)
+
+		bottom, top := float64(heights[0]), float64(heights[len(heights)-1])
+		for layer := int32(-10); layer < 40; layer++ {
+			z := float64(layer) * layerHeight
+			segments := (*segmentsByLayer)[layer]
+
+			// Only planes through the solid cut it: a plane through the
+			// bottom face counts as being below the solid, and one through
+			// the top face as being inside it.
+			if inside := z > bottom && z <= top; inside != (len(segments) > 0) {
+				t.Logf("seed %v: layer %v at %v: %v segments for heights %v", seed, layer, z, len(segments), heights)
+				return false
+			}
+
+			// Every segment starts where another ends, so the contours close.
+			ends := make(map[Point]int)
+			for _, s := range segments {
+				if s.Start == s.End {
+					t.Logf("seed %v: layer %v: degenerate segment %v", seed, layer, s)
+					return false
+				}
+				ends[s.Start]++
+				ends[s.End]--
+			}
+			for p, n := range ends {
+				if n != 0 {
+					t.Logf("seed %v: layer %v: contour open at %v", seed, layer, p)
+					return false
+				}
+			}
+
+			// And they go around counter-clockwise.
+			if len(segments) > 0 && signedArea(segments) <= 0 {
+				t.Logf("seed %v: layer %v: area %v", seed, layer, signedArea(segments))
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSliceBox(t *testing.T) {
+	facets := box(Vector{0, 0, 0}, Vector{1, 2, 1})
+	segmentsByLayer, minLayer, maxLayer := FacetsByLayer(&facets, 0.25)
+
+	// the plane at the bottom only touches the box, so it doesn't cut it
+	if minLayer != 1 || maxLayer != 4 {
+		t.Errorf("Expected layers %v to %v, got %v to %v", 1, 4, minLayer, maxLayer)
+	}
+	for layer := minLayer; layer <= maxLayer; layer++ {
+		segments := (*segmentsByLayer)[layer]
+		if area := signedArea(segments); area != 2 {
+			t.Errorf("Layer %v: expected area %v, got %v", layer, 2, area)
+		}
+	}
+}
+
+func TestSliceFacetOnPlane(t *testing.T) {
+	f := Facet{Vertex1: Vector{0, 0, 1}, Vertex2: Vector{1, 0, 1}, Vertex3: Vector{0, 1, 1}}
+	if _, ok := SliceFacet(&f, 1); ok {
+		t.Error("Expected no segment for a facet on the plane")
+	}
+
+	// an edge on the plane, with the facet below it
+	f = Facet{Vertex1: Vector{0, 0, 0}, Vertex2: Vector{1, 0, 1}, Vertex3: Vector{0, 0, 1}}
+	s, ok := SliceFacet(&f, 1)
+	if !ok || s.Start != (Point{0, 0}) || s.End != (Point{1, 0}) {
+		t.Errorf("Expected the edge on the plane, got %v", s)
+	}
+
+	// and with the facet above it
+	f = Facet{Vertex1: Vector{0, 0, 2}, Vertex2: Vector{0, 0, 1}, Vertex3: Vector{1, 0, 1}}
+	if _, ok := SliceFacet(&f, 1); ok {
+		t.Error("Expected no segment for a facet above the plane")
+	}
+}
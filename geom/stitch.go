@@ -0,0 +1,193 @@
+package geom
+
+import (
+	"log/slog"
+	"math"
+)
+
+// Contours are the paths obtained by stitching together the segments of a
+// layer. Closed paths don't repeat their first point at the end, while
+// open ones end with the end point of their last segment.
+type Contours struct {
+	Closed []Path
+	Open   []Path
+}
+
+// StitchOptions control how Stitch links segments.
+type StitchOptions struct {
+	// Tolerance is the distance within which the end of a segment and the
+	// start of another are joined, when they don't share a facet edge.
+	Tolerance float32
+	// Logger receives the debug traces of the stitching, if set.
+	Logger *slog.Logger
+}
+
+// Stitch links the segments of a layer into paths, following each segment
+// with the one starting on the same facet edge it ends on. When there is
+// none, which happens when the mesh has holes or has not been welded, the
+// segment starting closest to its end, within tolerance, is used instead.
+//
+// Paths keep the orientation of the segments, so closed paths go around
+// counter-clockwise for outer boundaries and clockwise for holes. The
+// result only depends on the order of the segments.
+func Stitch(segments []Segment, options StitchOptions) *Contours {
+	st := newStitcher(segments, options.Tolerance)
+	contours := new(Contours)
+
+	for i := range segments {
+		if st.used[i] {
+			continue
+		}
+
+		chain := []int{i}
+		st.used[i] = true
+		closed := false
+		for {
+			last := &segments[chain[len(chain)-1]]
+			if st.closes(last, &segments[chain[0]]) {
+				closed = true
+				break
+			}
+			next := st.next(last)
+			if next < 0 {
+				break
+			}
+			st.used[next] = true
+			chain = append(chain, next)
+		}
+
+		if !closed {
+			// The chain may go further back than where we started.
+			for {
+				previous := st.previous(&segments[chain[0]])
+				if previous < 0 {
+					break
+				}
+				st.used[previous] = true
+				chain = append([]int{previous}, chain...)
+			}
+		}
+
+		path := make(Path, 0, len(chain)+1)
+		for _, j := range chain {
+			path = append(path, segments[j].Start)
+		}
+		if closed {
+			contours.Closed = append(contours.Closed, path)
+		} else {
+			subsystemLogger(options.Logger, "stitch").Debug("path doesn't close", "start", path[0], "segments", len(chain))
+			path = append(path, segments[chain[len(chain)-1]].End)
+			contours.Open = append(contours.Open, path)
+		}
+	}
+
+	return contours
+}
+
+// stitcher indexes the segments by the keys of their endpoints, and by the
+// position of their endpoints for the tolerance based fallback.
+type stitcher struct {
+	segments  []Segment
+	tolerance float32
+	used      []bool
+
+	starts     map[EdgeKey][]int
+	ends       map[EdgeKey][]int
+	startCells map[[2]int64][]int
+	endCells   map[[2]int64][]int
+}
+
+func newStitcher(segments []Segment, tolerance float32) *stitcher {
+	st := &stitcher{
+		segments:   segments,
+		tolerance:  tolerance,
+		used:       make([]bool, len(segments)),
+		starts:     make(map[EdgeKey][]int),
+		ends:       make(map[EdgeKey][]int),
+		startCells: make(map[[2]int64][]int),
+		endCells:   make(map[[2]int64][]int),
+	}
+	for i, s := range segments {
+		st.starts[s.StartEdge] = append(st.starts[s.StartEdge], i)
+		st.ends[s.EndEdge] = append(st.ends[s.EndEdge], i)
+		if tolerance > 0 {
+			st.startCells[st.cell(s.Start)] = append(st.startCells[st.cell(s.Start)], i)
+			st.endCells[st.cell(s.End)] = append(st.endCells[st.cell(s.End)], i)
+		}
+	}
+	return st
+}
+
+// closes returns true if the segment last leads back to the segment first.
+func (st *stitcher) closes(last, first *Segment) bool {
+	if last.EndEdge == first.StartEdge {
+		return true
+	}
+	if _, ok := st.firstUnused(st.starts[last.EndEdge]); ok {
+		// there's a better match to continue with
+		return false
+	}
+	return st.tolerance > 0 && distance(last.End, first.Start) <= st.tolerance
+}
+
+// next returns the unused segment following s, or -1.
+func (st *stitcher) next(s *Segment) int {
+	if i, ok := st.firstUnused(st.starts[s.EndEdge]); ok {
+		return i
+	}
+	return st.nearest(st.startCells, s.End, func(i int) Point { return st.segments[i].Start })
+}
+
+// previous returns the unused segment preceding s, or -1.
+func (st *stitcher) previous(s *Segment) int {
+	if i, ok := st.firstUnused(st.ends[s.StartEdge]); ok {
+		return i
+	}
+	return st.nearest(st.endCells, s.Start, func(i int) Point { return st.segments[i].End })
+}
+
+func (st *stitcher) firstUnused(candidates []int) (int, bool) {
+	for _, i := range candidates {
+		if !st.used[i] {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// nearest returns the unused segment whose endpoint is closest to p and
+// within tolerance, preferring the first one in case of ties, or -1.
+func (st *stitcher) nearest(cells map[[2]int64][]int, p Point, endpoint func(int) Point) int {
+	if st.tolerance <= 0 {
+		return -1
+	}
+	best, bestDistance := -1, st.tolerance
+	cell := st.cell(p)
+	for dx := int64(-1); dx <= 1; dx++ {
+		for dy := int64(-1); dy <= 1; dy++ {
+			for _, i := range cells[[2]int64{cell[0] + dx, cell[1] + dy}] {
+				if st.used[i] {
+					continue
+				}
+				d := distance(endpoint(i), p)
+				if d < bestDistance || (d == bestDistance && (best < 0 || i < best)) {
+					best, bestDistance = i, d
+				}
+			}
+		}
+	}
+	return best
+}
+
+func (st *stitcher) cell(p Point) [2]int64 {
+	return [2]int64{
+		int64(math.Floor(float64(p[0] / st.tolerance))),
+		int64(math.Floor(float64(p[1] / st.tolerance))),
+	}
+}
+
+// distance returns the euclidean distance between two points.
+func distance(a, b Point) float32 {
+	dx, dy := float64(a[0]-b[0]), float64(a[1]-b[1])
+	return float32(math.Sqrt(dx*dx + dy*dy))
+}
@@ -0,0 +1,127 @@
+package geom
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+func TestStitchBox(t *testing.T) {
+	facets := box(Vector{0, 0, 0}, Vector{1, 2, 1})
+	segmentsByLayer, _, _ := FacetsByLayer(&facets, 0.25)
+
+	contours := Stitch((*segmentsByLayer)[2], StitchOptions{})
+	if len(contours.Closed) != 1 || len(contours.Open) != 0 {
+		t.Fatalf("Expected a single closed path, got %+v", contours)
+	}
+	if area := contours.Closed[0].Area(); area != 2 {
+		t.Errorf("Expected area %v, got %v", 2, area)
+	}
+}
+
+func TestStitchHoles(t *testing.T) {
+	// a frame: a box with a box shaped hole through it
+	var facets []Facet
+	for _, f := range box(Vector{1, 1, 0}, Vector{2, 2, 1}) {
+		f.Vertex1, f.Vertex2 = f.Vertex2, f.Vertex1
+		facets = append(facets, f)
+	}
+	facets = append(facets, box(Vector{0, 0, 0}, Vector{3, 3, 1})...)
+	segmentsByLayer, _, _ := FacetsByLayer(&facets, 0.5)
+
+	contours := Stitch((*segmentsByLayer)[1], StitchOptions{})
+	if len(contours.Closed) != 2 {
+		t.Fatalf("Expected two closed paths, got %+v", contours)
+	}
+	if a, b := contours.Closed[0].Area(), contours.Closed[1].Area(); a != -1 || b != 9 {
+		t.Errorf("Expected areas %v and %v, got %v and %v", -1, 9, a, b)
+	}
+}
+
+func TestStitchWithGaps(t *testing.T) {
+	// a square whose corners don't quite match, as from an unwelded mesh
+	segments := []Segment{
+		{Start: Point{0, 0}, End: Point{1, 0}, StartEdge: EdgeKey{{0}}, EndEdge: EdgeKey{{1}}},
+		{Start: Point{1, 0.00001}, End: Point{1, 1}, StartEdge: EdgeKey{{2}}, EndEdge: EdgeKey{{3}}},
+		{Start: Point{1, 1}, End: Point{0, 1}, StartEdge: EdgeKey{{3}}, EndEdge: EdgeKey{{4}}},
+		{Start: Point{0.00001, 1}, End: Point{0, 0.00001}, StartEdge: EdgeKey{{5}}, EndEdge: EdgeKey{{6}}},
+	}
+
+	contours := Stitch(segments, StitchOptions{})
+	if len(contours.Closed) != 0 || len(contours.Open) != 3 {
+		t.Fatalf("Expected three open paths without tolerance, got %+v", contours)
+	}
+	if !reflect.DeepEqual(contours.Open[1], Path{{1, 0.00001}, {1, 1}, {0, 1}}) {
+		t.Errorf("Unexpected second open path %v", contours.Open[1])
+	}
+
+	contours = Stitch(segments, StitchOptions{Tolerance: 0.001})
+	if len(contours.Closed) != 1 || len(contours.Open) != 0 {
+		t.Errorf("Expected a closed path with tolerance, got %+v", contours)
+	}
+
+	// an open chain found starting from its middle
+	contours = Stitch(segments[1:], StitchOptions{Tolerance: 0.001})
+	if len(contours.Open) != 1 || !reflect.DeepEqual(contours.Open[0], Path{{1, 0.00001}, {1, 1}, {0.00001, 1}, {0, 0.00001}}) {
+		t.Errorf("Expected a single open path, got %+v", contours)
+	}
+}
+
+func TestStitchRandomPrisms(t *testing.T) {
+	const layerHeight = 0.25
+
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		facets := prism(r, randomHeights(r, layerHeight))
+		segmentsByLayer, minLayer, maxLayer := FacetsByLayer(&facets, layerHeight)
+
+		for layer := minLayer; layer <= maxLayer; layer++ {
+			segments := (*segmentsByLayer)[layer]
+			contours := Stitch(segments, StitchOptions{})
+			if len(contours.Closed) != 1 || len(contours.Open) != 0 {
+				t.Logf("seed %v: layer %v: %v closed and %v open paths", seed, layer, len(contours.Closed), len(contours.Open))
+				return false
+			}
+			if contours.Closed[0].Area() <= 0 {
+				t.Logf("seed %v: layer %v: area %v", seed, layer, contours.Closed[0].Area())
+				return false
+			}
+
+			// the same segments in another order give the same loop,
+			// possibly starting elsewhere
+			r.Shuffle(len(segments), func(i, j int) { segments[i], segments[j] = segments[j], segments[i] })
+			again := Stitch(segments, StitchOptions{})
+			if len(again.Closed) != 1 || !sameLoop(again.Closed[0], contours.Closed[0]) {
+				t.Logf("seed %v: layer %v: different loops after shuffling", seed, layer)
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 200}); err != nil {
+		t.Error(err)
+	}
+}
+
+// sameLoop returns true if the two paths are the same loop, starting from
+// any point.
+func sameLoop(a, b Path) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for offset := range b {
+		same := true
+		for i := range a {
+			if a[i] != b[(i+offset)%len(b)] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return true
+		}
+	}
+	return false
+}
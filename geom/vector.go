@@ -0,0 +1,60 @@
+package geom
+
+import (
+	"math"
+)
+
+// Add returns the sum of the two vectors.
+func (v Vector) Add(w Vector) Vector {
+	return Vector{v[0] + w[0], v[1] + w[1], v[2] + w[2]}
+}
+
+// Sub returns the difference of the two vectors.
+func (v Vector) Sub(w Vector) Vector {
+	return Vector{v[0] - w[0], v[1] - w[1], v[2] - w[2]}
+}
+
+// Scale returns the vector multiplied by k.
+func (v Vector) Scale(k float32) Vector {
+	return Vector{v[0] * k, v[1] * k, v[2] * k}
+}
+
+// Dot returns the dot product of the two vectors.
+func (v Vector) Dot(w Vector) float32 {
+	return v[0]*w[0] + v[1]*w[1] + v[2]*w[2]
+}
+
+// Cross returns the cross product of the two vectors.
+func (v Vector) Cross(w Vector) Vector {
+	return Vector{
+		v[1]*w[2] - v[2]*w[1],
+		v[2]*w[0] - v[0]*w[2],
+		v[0]*w[1] - v[1]*w[0],
+	}
+}
+
+// Length returns the euclidean length of the vector.
+func (v Vector) Length() float32 {
+	return float32(math.Sqrt(float64(v.Dot(v))))
+}
+
+// Vertices returns the three vertices of the facet.
+func (f *Facet) Vertices() [3]Vector {
+	return [3]Vector{f.Vertex1, f.Vertex2, f.Vertex3}
+}
+
+// Normalize returns the vector scaled to unit length, or the zero vector
+// if it has no length.
+func (v Vector) Normalize() Vector {
+	l := v.Length()
+	if l == 0 {
+		return Vector{}
+	}
+	return v.Scale(1 / l)
+}
+
+// normal returns the unit normal of the triangle abc, pointing towards the
+// side from which the vertices are seen in counter-clockwise order.
+func normal(a, b, c Vector) Vector {
+	return b.Sub(a).Cross(c.Sub(a)).Normalize()
+}
@@ -0,0 +1,36 @@
+package infill
+
+import (
+	"github.com/stefanom/peano/geom"
+)
+
+// Concentric fills regions with loops following their boundary, each
+// inside the previous one.
+type Concentric struct {
+	Density   float64
+	LineWidth float64
+}
+
+// Fill returns the loops filling the region, closed by repeating their
+// first point, from the outermost one in.
+func (c *Concentric) Fill(region []geom.Polygon, index int32, z float64) []geom.Path {
+	if c.Density <= 0 || c.LineWidth <= 0 || len(region) == 0 {
+		return nil
+	}
+	s := spacing(c.LineWidth, c.Density)
+
+	var loops []geom.Path
+	for k := 0; ; k++ {
+		inset := region
+		if k > 0 {
+			inset = geom.Offset(region, -float64(k)*s, geom.DefaultOffsetOptions)
+		}
+		if len(inset) == 0 {
+			break
+		}
+		for _, path := range geom.PolygonPaths(inset) {
+			loops = append(loops, append(path[:len(path):len(path)], path[0]))
+		}
+	}
+	return loops
+}
@@ -0,0 +1,36 @@
+package infill
+
+import (
+	"github.com/stefanom/peano/geom"
+	"math"
+)
+
+// Cubic fills regions with lines in three directions, moving across as the
+// layers go up so that they stack into cubes standing on a corner.
+type Cubic struct {
+	Density   float64
+	LineWidth float64
+	// Angle is the direction of the first set of lines, in degrees
+	// counter-clockwise from the X axis. The others are turned by 60 and
+	// 120 degrees from it.
+	Angle float64
+}
+
+// Fill returns the lines filling the region on the layer at height z,
+// ordered for printing.
+func (c *Cubic) Fill(region []geom.Polygon, index int32, z float64) []geom.Path {
+	if c.Density <= 0 || c.LineWidth <= 0 || len(region) == 0 {
+		return nil
+	}
+
+	// The faces of a cube standing on a corner slope so that their
+	// sections move by z/√2 across.
+	s := 3 * spacing(c.LineWidth, c.Density)
+	shift := z / math.Sqrt2
+
+	var lines []geom.Path
+	for _, angle := range []float64{0, 60, 120} {
+		lines = append(lines, parallelLines(region, c.Angle+angle, s, shift)...)
+	}
+	return Order(geom.ClipPaths(lines, region), startPoint(region))
+}
@@ -0,0 +1,185 @@
+package infill
+
+import (
+	"github.com/stefanom/peano/geom"
+	"math"
+	"sort"
+)
+
+// gyroidArea is the area of the gyroid surface in a cube of unit period.
+const gyroidArea = 3.09
+
+// gyroidSamples is the number of samples per period used to trace the
+// gyroid.
+const gyroidSamples = 16
+
+// Gyroid fills regions with the sections of a gyroid, a surface dividing
+// space in two interlocking halves. The lines change from layer to layer,
+// making infill about as strong in every direction.
+type Gyroid struct {
+	Density   float64
+	LineWidth float64
+	// Angle turns the gyroid around the Z axis, in degrees
+	// counter-clockwise.
+	Angle float64
+}
+
+// Fill returns the lines filling the region on the layer at height z,
+// ordered for printing.
+func (g *Gyroid) Fill(region []geom.Polygon, index int32, z float64) []geom.Path {
+	if g.Density <= 0 || g.LineWidth <= 0 || len(region) == 0 {
+		return nil
+	}
+
+	// Walls of width w on a surface of area A per period p cubed fill
+	// A·w/p of the volume, but their sections on a layer, slanting every
+	// way, cover π/4 of that on average.
+	period := gyroidArea * math.Pi / 4 * g.LineWidth / math.Min(g.Density, 1)
+	scale := 2 * math.Pi / period
+	step := period / gyroidSamples
+
+	f := newFrame(g.Angle)
+	minX, minY, maxX, maxY := f.bounds(region)
+	minX, minY = minX-step, minY-step
+	nx := int(math.Ceil((maxX-minX)/step)) + 2
+	ny := int(math.Ceil((maxY-minY)/step)) + 2
+
+	sz, cz := math.Sincos(scale * z)
+	values := make([]float64, nx*ny)
+	for j := 0; j < ny; j++ {
+		sy, cy := math.Sincos(scale * (minY + float64(j)*step))
+		for i := 0; i < nx; i++ {
+			sx, cx := math.Sincos(scale * (minX + float64(i)*step))
+			values[j*nx+i] = sx*cy + sy*cz + sz*cx
+		}
+	}
+
+	var lines []geom.Path
+	for _, curve := range isolines(values, nx, ny) {
+		points := make([][2]float64, len(curve))
+		for i, p := range curve {
+			points[i] = [2]float64{minX + p[0]*step, minY + p[1]*step}
+		}
+		lines = append(lines, f.path(points))
+	}
+	return Order(geom.ClipPaths(lines, region), startPoint(region))
+}
+
+// isolines traces the curves where the values sampled on a grid of nx by
+// ny points are zero, with marching squares. The points of the curves are
+// in grid units.
+func isolines(values []float64, nx, ny int) [][][2]float64 {
+	// The curves cross the sides of the grid cells, which are identified
+	// by the index of their lower left end, times two, plus one for the
+	// vertical ones.
+	horizontal := func(i, j int) int { return 2 * (j*nx + i) }
+	vertical := func(i, j int) int { return 2*(j*nx+i) + 1 }
+	positive := func(i, j int) bool { return values[j*nx+i] >= 0 }
+
+	crossing := func(side int) [2]float64 {
+		k := side / 2
+		i, j := k%nx, k/nx
+		a, b := values[k], values[k+1]
+		if side%2 == 1 {
+			b = values[k+nx]
+		}
+		t := a / (a - b)
+		if side%2 == 0 {
+			return [2]float64{float64(i) + t, float64(j)}
+		}
+		return [2]float64{float64(i), float64(j) + t}
+	}
+
+	links := make(map[int][]int)
+	link := func(a, b int) {
+		links[a] = append(links[a], b)
+		links[b] = append(links[b], a)
+	}
+
+	for j := 0; j+1 < ny; j++ {
+		for i := 0; i+1 < nx; i++ {
+			p00, p10 := positive(i, j), positive(i+1, j)
+			p01, p11 := positive(i, j+1), positive(i+1, j+1)
+			bottom, right := horizontal(i, j), vertical(i+1, j)
+			top, left := horizontal(i, j+1), vertical(i, j)
+
+			var sides []int
+			if p00 != p10 {
+				sides = append(sides, bottom)
+			}
+			if p10 != p11 {
+				sides = append(sides, right)
+			}
+			if p11 != p01 {
+				sides = append(sides, top)
+			}
+			if p01 != p00 {
+				sides = append(sides, left)
+			}
+
+			switch len(sides) {
+			case 2:
+				link(sides[0], sides[1])
+			case 4:
+				// a saddle: the center decides which corners are joined
+				center := values[j*nx+i] + values[j*nx+i+1] + values[(j+1)*nx+i] + values[(j+1)*nx+i+1]
+				if (center >= 0) == p00 {
+					link(bottom, right)
+					link(top, left)
+				} else {
+					link(left, bottom)
+					link(right, top)
+				}
+			}
+		}
+	}
+
+	// Follow the links from the ends of the open curves first, then
+	// around the closed ones, in a fixed order.
+	sides := make([]int, 0, len(links))
+	for side := range links {
+		sides = append(sides, side)
+	}
+	sort.Ints(sides)
+
+	visited := make(map[int]bool)
+	var curves [][][2]float64
+	follow := func(start int) {
+		var curve [][2]float64
+		prev, side := -1, start
+		for side >= 0 && !visited[side] {
+			visited[side] = true
+			curve = append(curve, crossing(side))
+			next := -1
+			for _, n := range links[side] {
+				if n != prev && !visited[n] {
+					next = n
+					break
+				}
+			}
+			if next < 0 && len(curve) > 2 {
+				// close the loop
+				for _, n := range links[side] {
+					if n == start {
+						curve = append(curve, crossing(start))
+					}
+				}
+			}
+			prev, side = side, next
+		}
+		if len(curve) >= 2 {
+			curves = append(curves, curve)
+		}
+	}
+	for _, side := range sides {
+		if len(links[side]) == 1 && !visited[side] {
+			follow(side)
+		}
+	}
+	for _, side := range sides {
+		if !visited[side] {
+			follow(side)
+		}
+	}
+	return curves
+}
@@ -0,0 +1,55 @@
+package infill
+
+import (
+	"github.com/stefanom/peano/geom"
+	"math"
+)
+
+// Honeycomb fills regions with a grid of hexagons, the same on every
+// layer, so that they stack into hexagonal prisms.
+type Honeycomb struct {
+	Density   float64
+	LineWidth float64
+	// Angle turns the grid, in degrees counter-clockwise. With no angle,
+	// the hexagons have a corner pointing up the Y axis.
+	Angle float64
+}
+
+// Fill returns the lines filling the region, ordered for printing.
+func (h *Honeycomb) Fill(region []geom.Polygon, index int32, z float64) []geom.Path {
+	if h.Density <= 0 || h.LineWidth <= 0 || len(region) == 0 {
+		return nil
+	}
+
+	// Every hexagon has half of its six sides of length a to itself, so a
+	// line of width w covers 2w/(a√3) of its area.
+	a := 2 * h.LineWidth / (math.Sqrt(3) * math.Min(h.Density, 1))
+	width := math.Sqrt(3) * a
+
+	f := newFrame(h.Angle)
+	minX, minY, maxX, maxY := f.bounds(region)
+	first, last := math.Floor(minY/(3*a))-1, math.Ceil(maxY/(3*a))+1
+
+	// The sides of the hexagons are laid out in columns zig-zagging to
+	// the right of every vertical side, plus the single sides joining them
+	// going to the left.
+	var lines []geom.Path
+	for k := math.Floor(minX/width) - 1; k*width <= maxX+width; k++ {
+		x := k*width + width/2
+		var zigzag [][2]float64
+		for j := first; j <= last; j++ {
+			y := j * 3 * a
+			zigzag = append(zigzag,
+				[2]float64{x, y - a/2},
+				[2]float64{x, y + a/2},
+				[2]float64{x + width/2, y + a},
+				[2]float64{x + width/2, y + 2*a})
+			lines = append(lines,
+				f.path([][2]float64{{x, y + a/2}, {x - width/2, y + a}}),
+				f.path([][2]float64{{x - width/2, y + 2*a}, {x, y + 5*a/2}}))
+		}
+		lines = append(lines, f.path(zigzag))
+	}
+
+	return Order(geom.ClipPaths(lines, region), startPoint(region))
+}
@@ -0,0 +1,58 @@
+// Package infill generates the toolpaths filling the inside of the layers
+// of a model.
+package infill
+
+import (
+	"github.com/stefanom/peano/geom"
+	"math"
+)
+
+// DefaultOverlap is the fraction of the line width by which infill lines
+// overlap the innermost perimeter, to bond with it.
+const DefaultOverlap = 0.15
+
+// Region returns the region where the center of infill lines of the given
+// width can go, inside the innermost perimeter of a layer, which is the
+// path followed by the center of its line.
+func Region(innermost []geom.Polygon, width, overlap float64) []geom.Polygon {
+	return geom.Offset(innermost, -width*(1-overlap), geom.DefaultOffsetOptions)
+}
+
+// Order sorts the paths so as to print them with as little travel as
+// possible, starting from a point and going to the nearest end of the
+// next path every time. Paths are reversed when printing them backwards
+// is shorter.
+func Order(paths []geom.Path, start geom.Point) []geom.Path {
+	ordered := make([]geom.Path, 0, len(paths))
+	used := make([]bool, len(paths))
+	at := start
+	for range paths {
+		best, reverse, nearest := -1, false, math.Inf(1)
+		for i, path := range paths {
+			if used[i] || len(path) == 0 {
+				continue
+			}
+			if d := distance(at, path[0]); d < nearest {
+				best, reverse, nearest = i, false, d
+			}
+			if d := distance(at, path[len(path)-1]); d < nearest {
+				best, reverse, nearest = i, true, d
+			}
+		}
+		if best < 0 {
+			break
+		}
+		used[best] = true
+		path := paths[best]
+		if reverse {
+			path = path.Reverse()
+		}
+		ordered = append(ordered, path)
+		at = path[len(path)-1]
+	}
+	return ordered
+}
+
+func distance(a, b geom.Point) float64 {
+	return math.Hypot(float64(a[0]-b[0]), float64(a[1]-b[1]))
+}
@@ -0,0 +1,41 @@
+package infill
+
+import (
+	"github.com/stefanom/peano/geom"
+	"math"
+	"testing"
+)
+
+func TestOrder(t *testing.T) {
+	paths := []geom.Path{
+		{{0, 2}, {10, 2}},
+		{{10, 1}, {0, 1}},
+		{{0, 0}, {10, 0}},
+	}
+
+	ordered := Order(paths, geom.Point{0, 0})
+
+	// zig-zagging up from the origin
+	expected := []geom.Path{
+		{{0, 0}, {10, 0}},
+		{{10, 1}, {0, 1}},
+		{{0, 2}, {10, 2}},
+	}
+	if len(ordered) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, ordered)
+	}
+	for i := range expected {
+		if ordered[i][0] != expected[i][0] || ordered[i][1] != expected[i][1] {
+			t.Errorf("Expected %v, got %v", expected, ordered)
+			break
+		}
+	}
+}
+
+func TestRegion(t *testing.T) {
+	innermost := geom.Nest([]geom.Path{{{0, 0}, {10, 0}, {10, 10}, {0, 10}}})
+	r := Region(innermost, 0.5, 0.2)
+	if len(r) != 1 || math.Abs(r[0].Area()-9.2*9.2) > 1e-3 {
+		t.Errorf("Unexpected region %+v", r)
+	}
+}
@@ -0,0 +1,132 @@
+package infill
+
+import (
+	"fmt"
+	"github.com/stefanom/peano/geom"
+	"math"
+	"sort"
+)
+
+// Pattern fills the regions of layers with lines.
+type Pattern interface {
+	// Fill returns the lines filling the region on the layer with the
+	// given index and height, ordered for printing.
+	Fill(region []geom.Polygon, index int32, z float64) []geom.Path
+}
+
+// Options are the parameters patterns are built from.
+type Options struct {
+	// Density is the fraction of the region covered by the lines, from 0
+	// for nothing to 1 for solid infill.
+	Density float64
+	// LineWidth is the width of the extruded lines.
+	LineWidth float64
+	// Angle is the direction of the pattern, in degrees counter-clockwise
+	// from the X axis.
+	Angle float64
+}
+
+// Constructor builds a pattern from options.
+type Constructor func(options Options) Pattern
+
+var patterns = make(map[string]Constructor)
+
+// Register makes a pattern available by name. It panics if the name is
+// already taken.
+func Register(name string, constructor Constructor) {
+	if _, ok := patterns[name]; ok {
+		panic("infill: pattern " + name + " registered twice")
+	}
+	patterns[name] = constructor
+}
+
+// New returns the pattern registered with the given name.
+func New(name string, options Options) (Pattern, error) {
+	constructor, ok := patterns[name]
+	if !ok {
+		return nil, fmt.Errorf("infill: unknown pattern %q", name)
+	}
+	return constructor(options), nil
+}
+
+// Names returns the names of the registered patterns, sorted.
+func Names() []string {
+	names := make([]string, 0, len(patterns))
+	for name := range patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register("rectilinear", func(o Options) Pattern {
+		return &Rectilinear{Density: o.Density, LineWidth: o.LineWidth, Angle: o.Angle}
+	})
+	Register("grid", func(o Options) Pattern {
+		return &Rectilinear{Density: o.Density, LineWidth: o.LineWidth, Angle: o.Angle, Grid: true}
+	})
+	Register("honeycomb", func(o Options) Pattern {
+		return &Honeycomb{Density: o.Density, LineWidth: o.LineWidth, Angle: o.Angle}
+	})
+	Register("gyroid", func(o Options) Pattern {
+		return &Gyroid{Density: o.Density, LineWidth: o.LineWidth, Angle: o.Angle}
+	})
+	Register("cubic", func(o Options) Pattern {
+		return &Cubic{Density: o.Density, LineWidth: o.LineWidth, Angle: o.Angle}
+	})
+	Register("concentric", func(o Options) Pattern {
+		return &Concentric{Density: o.Density, LineWidth: o.LineWidth}
+	})
+}
+
+// frame is a coordinate system turned by an angle, in which patterns are
+// laid out before being turned into place.
+type frame struct {
+	cos, sin float64
+}
+
+func newFrame(degrees float64) frame {
+	radians := degrees * math.Pi / 180
+	return frame{math.Cos(radians), math.Sin(radians)}
+}
+
+// local returns the coordinates of a point in the frame.
+func (f frame) local(p geom.Point) (x, y float64) {
+	px, py := float64(p[0]), float64(p[1])
+	return px*f.cos + py*f.sin, -px*f.sin + py*f.cos
+}
+
+// world returns the point at the given coordinates in the frame.
+func (f frame) world(x, y float64) geom.Point {
+	return geom.Point{float32(x*f.cos - y*f.sin), float32(x*f.sin + y*f.cos)}
+}
+
+// bounds returns the extent of the region in the frame.
+func (f frame) bounds(region []geom.Polygon) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, path := range geom.PolygonPaths(region) {
+		for _, p := range path {
+			x, y := f.local(p)
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+	}
+	return
+}
+
+// path turns the points laid out in the frame into a path.
+func (f frame) path(points [][2]float64) geom.Path {
+	path := make(geom.Path, len(points))
+	for i, p := range points {
+		path[i] = f.world(p[0], p[1])
+	}
+	return path
+}
+
+// spacing returns the distance between lines of the given width covering
+// a fraction density of the area.
+func spacing(width, density float64) float64 {
+	return width / math.Min(density, 1)
+}
@@ -0,0 +1,113 @@
+package infill
+
+import (
+	"github.com/stefanom/peano/geom"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	expected := []string{"concentric", "cubic", "grid", "gyroid", "honeycomb", "rectilinear"}
+	if names := Names(); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+
+	if _, err := New("spiral", Options{}); err == nil {
+		t.Error("Expected an error for an unknown pattern")
+	}
+
+	p, err := New("grid", Options{Density: 0.3, LineWidth: 0.4, Angle: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := p.(*Rectilinear); !ok || !r.Grid || r.Density != 0.3 || r.LineWidth != 0.4 || r.Angle != 10 {
+		t.Errorf("Unexpected pattern %+v", p)
+	}
+}
+
+func TestPatternDensity(t *testing.T) {
+	// the lines cover about the requested fraction of the region, without
+	// leaving it
+	frame := region(20, 6)
+	area := frame[0].Area()
+	for _, name := range Names() {
+		for _, density := range []float64{0.15, 0.4} {
+			p, _ := New(name, Options{Density: density, LineWidth: 0.4, Angle: 30})
+			lines := p.Fill(frame, 3, 1.1)
+			if len(lines) == 0 {
+				t.Errorf("%v: no lines", name)
+				continue
+			}
+
+			// concentric loops follow the boundaries of the region
+			// wherever they are, which adds a lot on small regions
+			coverage := length(lines) * 0.4 / area
+			if name != "concentric" && math.Abs(coverage-density) > 0.2*density {
+				t.Errorf("%v: expected density %v, got %v", name, density, coverage)
+			}
+
+			for _, line := range lines {
+				for i := 0; i+1 < len(line); i++ {
+					mid := geom.Point{(line[i][0] + line[i+1][0]) / 2, (line[i][1] + line[i+1][1]) / 2}
+					if !frame[0].Contains(mid) && distanceToRegion(frame[0], mid) > 1e-3 {
+						t.Errorf("%v: %v out of the region", name, line)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestPatternLayers(t *testing.T) {
+	square := region(10, 0)
+	options := Options{Density: 0.2, LineWidth: 0.4}
+
+	// gyroid and cubic change with the height, honeycomb doesn't
+	for _, c := range []struct {
+		name    string
+		changes bool
+	}{{"gyroid", true}, {"cubic", true}, {"honeycomb", false}, {"concentric", false}} {
+		p, _ := New(c.name, options)
+		a, b := p.Fill(square, 1, 0.2), p.Fill(square, 2, 0.4)
+		if changes := !reflect.DeepEqual(a, b); changes != c.changes {
+			t.Errorf("%v: expected changes between layers to be %v", c.name, c.changes)
+		}
+	}
+}
+
+func TestConcentric(t *testing.T) {
+	c := &Concentric{Density: 1, LineWidth: 1}
+	loops := c.Fill(region(10, 0), 0, 0)
+
+	// squares shrinking by a millimeter on every side, down to nothing
+	if len(loops) != 5 {
+		t.Fatalf("Expected %v loops, got %v", 5, len(loops))
+	}
+	for i, loop := range loops {
+		if loop[0] != loop[len(loop)-1] {
+			t.Errorf("Loop %v is not closed", i)
+		}
+		size := 10 - 2*float64(i)
+		if l := length([]geom.Path{loop}); math.Abs(l-4*size) > 1e-3 {
+			t.Errorf("Loop %v: expected length %v, got %v", i, 4*size, l)
+		}
+	}
+}
+
+// distanceToRegion returns the distance from the point to the boundary of
+// the polygon.
+func distanceToRegion(p geom.Polygon, point geom.Point) float64 {
+	nearest := math.Inf(1)
+	for _, path := range p.Paths() {
+		for i := range path {
+			a, b := path[i], path[(i+1)%len(path)]
+			ax, ay := float64(a[0]), float64(a[1])
+			dx, dy := float64(b[0])-ax, float64(b[1])-ay
+			t := ((float64(point[0])-ax)*dx + (float64(point[1])-ay)*dy) / (dx*dx + dy*dy)
+			t = math.Max(0, math.Min(1, t))
+			nearest = math.Min(nearest, math.Hypot(ax+t*dx-float64(point[0]), ay+t*dy-float64(point[1])))
+		}
+	}
+	return nearest
+}
@@ -0,0 +1,74 @@
+package infill
+
+import (
+	"github.com/stefanom/peano/geom"
+	"math"
+)
+
+// Rectilinear fills regions with parallel straight lines, turning them by
+// a quarter turn every layer so that consecutive layers cross.
+type Rectilinear struct {
+	// Density is the fraction of the region covered by the lines, from 0
+	// for nothing to 1 for solid infill.
+	Density float64
+	// LineWidth is the width of the extruded lines.
+	LineWidth float64
+	// Angle is the direction of the lines on even layers, in degrees
+	// counter-clockwise from the X axis.
+	Angle float64
+	// Grid lays lines in both directions on every layer instead of
+	// alternating them, spacing them twice as much for the same density.
+	Grid bool
+}
+
+// Fill returns the lines filling the region on the layer with the given
+// index and height, ordered for printing. Lines are laid on a grid fixed
+// in space, so that they stack from one layer to the next whatever the
+// shape of the region.
+func (r *Rectilinear) Fill(region []geom.Polygon, index int32, z float64) []geom.Path {
+	if r.Density <= 0 || r.LineWidth <= 0 || len(region) == 0 {
+		return nil
+	}
+	s := spacing(r.LineWidth, r.Density)
+
+	var lines []geom.Path
+	if r.Grid {
+		lines = parallelLines(region, r.Angle, 2*s, 0)
+		lines = append(lines, parallelLines(region, r.Angle+90, 2*s, 0)...)
+	} else {
+		angle := r.Angle
+		if index%2 != 0 {
+			angle += 90
+		}
+		lines = parallelLines(region, angle, s, 0)
+	}
+
+	return Order(geom.ClipPaths(lines, region), startPoint(region))
+}
+
+// parallelLines returns lines in the given direction, in degrees, every
+// spacing across the region and long enough to go all the way through
+// it. The lines are the ones at a multiple of spacing from the origin,
+// moved across by shift.
+func parallelLines(region []geom.Polygon, angle, spacing, shift float64) []geom.Path {
+	f := newFrame(angle)
+	minX, minY, maxX, maxY := f.bounds(region)
+	minX, maxX = minX-spacing, maxX+spacing
+
+	var lines []geom.Path
+	for k := math.Ceil((minY - shift) / spacing); k*spacing+shift <= maxY; k++ {
+		y := k*spacing + shift
+		lines = append(lines, f.path([][2]float64{{minX, y}, {maxX, y}}))
+	}
+	return lines
+}
+
+// startPoint returns where printing the infill of a region starts from:
+// its lower left corner.
+func startPoint(region []geom.Polygon) geom.Point {
+	b := region[0].Bounds()
+	for i := range region {
+		b = b.Union(region[i].Bounds())
+	}
+	return b.Min
+}
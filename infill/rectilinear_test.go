@@ -0,0 +1,106 @@
+package infill
+
+import (
+	"github.com/stefanom/peano/geom"
+	"math"
+	"testing"
+)
+
+// region returns a square of the given size with a square hole in the
+// middle, off the grid of the lines.
+func region(size, hole float32) []geom.Polygon {
+	lo, hi := float32(0.25), 0.25+size
+	paths := []geom.Path{{{lo, lo}, {hi, lo}, {hi, hi}, {lo, hi}}}
+	if hole > 0 {
+		lo, hi = 0.25+(size-hole)/2, 0.25+(size+hole)/2
+		paths = append(paths, geom.Path{{lo, lo}, {hi, lo}, {hi, hi}, {lo, hi}})
+	}
+	return geom.Nest(paths)
+}
+
+// length returns the total length of the paths.
+func length(paths []geom.Path) float64 {
+	total := 0.0
+	for _, path := range paths {
+		for i := 0; i+1 < len(path); i++ {
+			total += distance(path[i], path[i+1])
+		}
+	}
+	return total
+}
+
+func TestRectilinearAlternates(t *testing.T) {
+	r := &Rectilinear{Density: 0.5, LineWidth: 0.5}
+	square := region(10, 0)
+
+	for index, horizontal := range []bool{true, false, true} {
+		lines := r.Fill(square, int32(index), 0)
+		// a line every millimeter, each 10mm long
+		if len(lines) != 10 {
+			t.Errorf("Layer %v: expected %v lines, got %v", index, 10, len(lines))
+		}
+		if l := length(lines); math.Abs(l-100) > 1e-3 {
+			t.Errorf("Layer %v: expected length %v, got %v", index, 100, l)
+		}
+		for _, line := range lines {
+			if (line[0][1] == line[1][1]) != horizontal {
+				t.Errorf("Layer %v: wrong direction of %v", index, line)
+			}
+			for _, p := range line {
+				if p[0] < 0.25-1e-4 || p[0] > 10.25+1e-4 || p[1] < 0.25-1e-4 || p[1] > 10.25+1e-4 {
+					t.Errorf("Layer %v: %v outside of the region", index, p)
+				}
+			}
+		}
+	}
+}
+
+func TestRectilinearGrid(t *testing.T) {
+	r := &Rectilinear{Density: 0.5, LineWidth: 0.5, Angle: 45, Grid: true}
+	lines := r.Fill(region(10, 0), 1, 0)
+
+	// the same density as alternating lines, in both directions
+	alternating := (&Rectilinear{Density: 0.5, LineWidth: 0.5, Angle: 45}).Fill(region(10, 0), 1, 0)
+	if l, expected := length(lines), length(alternating); math.Abs(l-expected) > 0.1*expected {
+		t.Errorf("Expected length about %v, got %v", expected, l)
+	}
+
+	directions := make(map[bool]int)
+	for _, line := range lines {
+		dx, dy := line[1][0]-line[0][0], line[1][1]-line[0][1]
+		directions[dx*dy > 0]++
+	}
+	if directions[true] == 0 || directions[false] == 0 {
+		t.Errorf("Expected lines in both directions, got %v", directions)
+	}
+}
+
+func TestRectilinearHole(t *testing.T) {
+	r := &Rectilinear{Density: 1, LineWidth: 0.4}
+	frame := region(10, 4)
+	lines := r.Fill(frame, 0, 0)
+
+	hole := frame[0].Holes[0]
+	for _, line := range lines {
+		for i := 0; i+1 < len(line); i++ {
+			mid := geom.Point{(line[i][0] + line[i+1][0]) / 2, (line[i][1] + line[i+1][1]) / 2}
+			if hole.Contains(mid) {
+				t.Errorf("%v goes through the hole", line)
+			}
+		}
+	}
+	if l, expected := length(lines), frame[0].Area()/0.4; math.Abs(l-expected) > 0.05*expected {
+		t.Errorf("Expected length about %v, got %v", expected, l)
+	}
+}
+
+func TestRectilinearEmpty(t *testing.T) {
+	r := &Rectilinear{Density: 0, LineWidth: 0.4}
+	if lines := r.Fill(region(10, 0), 0, 0); len(lines) != 0 {
+		t.Errorf("Expected no lines, got %v", lines)
+	}
+	r.Density = 0.2
+	if lines := r.Fill(nil, 0, 0); len(lines) != 0 {
+		t.Errorf("Expected no lines, got %v", lines)
+	}
+}
@@ -1,11 +1,22 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"github.com/stefanom/peano/geom"
+	"github.com/stefanom/peano/infill"
+	"github.com/stefanom/peano/printer"
+	"github.com/stefanom/peano/raster"
+	"github.com/stefanom/peano/slicer"
 	"github.com/stefanom/peano/stl"
+	"github.com/stefanom/peano/svg"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 func check(e error) {
@@ -14,67 +25,288 @@ func check(e error) {
 	}
 }
 
-func ExportSliceAsSVG(slicename string, segments *[]geom.Segment, paths *[]geom.Path) {
-	f, err := os.Create(slicename)
+// ExportSVG draws the layers, with their toolpaths if asked, in a single
+// file or in a file per layer named after it.
+func ExportSVG(name string, toolpaths []*slicer.Toolpaths) {
+	layers := make([]svg.Layer, len(toolpaths))
+	for i, t := range toolpaths {
+		if svgToolpaths {
+			layers[i] = svg.FromToolpaths(t)
+		} else {
+			layers[i] = svg.Layer{Layer: t.Layer}
+		}
+	}
+
+	if !svgPerLayer {
+		f, err := os.Create(name)
+		check(err)
+		defer f.Close()
+		w := bufio.NewWriter(f)
+		check(svg.WriteLayers(w, layers, &svg.DefaultOptions))
+		check(w.Flush())
+		return
+	}
+
+	bounds := svg.Bounds(layers)
+	ext := filepath.Ext(name)
+	for _, l := range layers {
+		f, err := os.Create(fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), l.Index, ext))
+		check(err)
+		w := bufio.NewWriter(f)
+		check(svg.WriteLayer(w, l, bounds, &svg.DefaultOptions))
+		check(w.Flush())
+		check(f.Close())
+	}
+}
+
+// PrintReport prints the defects found in a mesh.
+func PrintReport(report *geom.Report) {
+	fmt.Println("mesh analysis:")
+	fmt.Printf("  non-manifold edges: %d, facets: %s\n", len(report.NonManifoldEdges), facetList(report.NonManifoldFacets))
+	fmt.Printf("  boundary edges: %d, facets: %s\n", len(report.BoundaryEdges), facetList(report.BoundaryFacets))
+	fmt.Printf("  inconsistent winding edges: %d, facets: %s\n", len(report.InconsistentEdges), facetList(report.InconsistentFacets))
+	fmt.Printf("  degenerate facets: %s\n", facetList(report.DegenerateFacets))
+	fmt.Printf("  duplicate facets: %s\n", facetList(report.DuplicateFacets))
+	fmt.Printf("  missing normal facets: %s\n", facetList(report.MissingNormalFacets))
+	fmt.Printf("  wrong normal facets: %s\n", facetList(report.WrongNormalFacets))
+	if report.Printable() {
+		fmt.Println("  the mesh is printable")
+	} else if report.Watertight() {
+		fmt.Println("  the mesh is watertight")
+	} else {
+		fmt.Println("  the mesh is not watertight")
+	}
+}
+
+// facetList formats a list of facet indices, eliding it when too long.
+func facetList(facets []int) string {
+	const max = 10
+	if len(facets) <= max {
+		return fmt.Sprintf("%d %v", len(facets), facets)
+	}
+	return fmt.Sprintf("%d %v...", len(facets), facets[:max])
+}
+
+// PrintRegions prints a summary of the regions of a layer and of the
+// toolpaths filling them. Their paths are traced by the "layers" debug
+// subsystem.
+func PrintRegions(r *slicer.Regions, t *slicer.Toolpaths) {
+	layer := r.Layer
+	fmt.Printf("layer %d: %d islands, area %.3f, %d perimeters, solid area %.3f in %d lines, sparse area %.3f in %d lines\n",
+		layer.Index, len(layer.Islands), layer.Area(), len(r.Perimeters), geom.Area(r.Solid), len(t.Solid), geom.Area(r.Sparse), len(t.Sparse))
+
+	log := logger.With("subsystem", "layers", "layer", layer.Index)
+	for _, island := range layer.Islands {
+		log.Debug("island", "outer", island.Outer, "holes", island.Holes)
+	}
+	for _, path := range t.Perimeters {
+		log.Debug("perimeter", "path", path)
+	}
+	for _, line := range t.Solid {
+		log.Debug("solid infill", "path", line)
+	}
+	for _, line := range t.Sparse {
+		log.Debug("sparse infill", "path", line)
+	}
+}
+
+var logger = slog.New(slog.DiscardHandler)
+
+// loadSettings resolves the printer settings from the profiles, with the
+// flags given explicitly overriding them.
+func loadSettings() *printer.Settings {
+	overrides := []string{settingOverrides}
+	keys := map[string]string{
+		"layerHeight":        "layer_height",
+		"temperature":        "temperature",
+		"printSpeed":         "print_speed",
+		"bedTemperature":     "bed_temperature",
+		"chamberTemperature": "chamber_temperature",
+		"fanSpeed":           "fan_speed",
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if key, ok := keys[f.Name]; ok {
+			overrides = append(overrides, key+"="+f.Value.String())
+		}
+	})
+
+	var files []string
+	if profiles != "" {
+		files = strings.Split(profiles, ",")
+	}
+	settings, err := printer.LoadSettings(files, printerProfile, materialProfile, strings.Join(overrides, ","))
 	check(err)
-	defer f.Close()
-
-	fmt.Fprintln(f, "<svg xmlns=\"http://www.w3.org/2000/svg\">")
-	// fmt.Fprintln(f, " <g transform=\"translate(200,200)\">")
-	// fmt.Fprintf(f, "  <path stroke=\"black\" d=\"M %v,%v ", (*paths)[0][0], (*paths)[0][1])
-	// for _, path := range (*paths)[1:] {
-	// 	fmt.Fprintf(f, "L %v,%v", path[0], path[1])
-	// }
-	// fmt.Fprintln(f, "\"/>")
-	// fmt.Fprintln(f, " </g>")
-	fmt.Fprintln(f, " <g transform=\"translate(400,200)\">")
-	for _, segment := range *segments {
-		fmt.Fprintf(f, "  <line x1=\"%v\" y1=\"%v\" x2=\"%v\" y2=\"%v\" stroke=\"black\" stroke-width=\"1\" />\n", segment.Start[0], segment.Start[1], segment.End[0], segment.End[1])
-	}
-	fmt.Fprintln(f, " </g>")
-	fmt.Fprintln(f, "</svg>")
+
+	for key, file := range map[string]string{"start_gcode": startGCode, "end_gcode": endGCode, "layer_gcode": layerGCode} {
+		if file != "" {
+			template, err := os.ReadFile(file)
+			check(err)
+			check(settings.Set(key, string(template)))
+		}
+	}
+	check(settings.Validate())
+	return settings
+}
+
+// newPrinter returns the printer writing G-code to w.
+func newPrinter(w io.Writer, settings *printer.Settings) *printer.Printer {
+	p := &printer.Printer{Output: w}
+	settings.Apply(p)
+	return p
 }
 
 var filename string
 var layerHeight float64
 var exportAscii bool
+var analyze bool
+var repair bool
+var recomputeNormals bool
+var workers int
+var debug string
+var weldTolerance float64
+var perimeters int
+var extrusionWidth float64
+var infillPattern string
+var infillDensity float64
+var infillAngle float64
+var topLayers int
+var bottomLayers int
+var gcode string
+var temperature float64
+var printSpeed float64
+var bedTemperature float64
+var chamberTemperature float64
+var fanSpeed float64
+var profiles string
+var printerProfile string
+var materialProfile string
+var settingOverrides string
+var startGCode string
+var endGCode string
+var layerGCode string
+var svgFile string
+var svgPerLayer bool
+var svgToolpaths bool
+var rasterFile string
+var pixelSize float64
+var antiAliasing int
+var exposure float64
+var bottomExposure float64
+var bottomExposureLayers int
 
 func init() {
 	flag.StringVar(&filename, "file", "", "The filename of the STL file to parse.")
 	flag.Float64Var(&layerHeight, "layerHeight", 0.2, "The layer height to use for slicing.")
 	flag.BoolVar(&exportAscii, "exportAscii", false, "Whether to export the parsed STL file as ASCII.")
+	flag.BoolVar(&analyze, "analyze", false, "Whether to report the defects of the mesh.")
+	flag.BoolVar(&repair, "repair", false, "Whether to repair the mesh before slicing it.")
+	flag.BoolVar(&recomputeNormals, "recomputeNormals", false, "Whether to ignore the normals in the file and compute them from the vertices.")
+	flag.IntVar(&workers, "workers", 1, "The number of layers to slice in parallel. With more than one, the whole model is loaded in memory first.")
+	flag.StringVar(&debug, "debug", "", "The comma separated subsystems to trace on stderr (slice, stitch, repair, offset, layers or all).")
+	flag.Float64Var(&weldTolerance, "weldTolerance", 1e-5, "The distance within which vertices are considered the same.")
+	flag.IntVar(&perimeters, "perimeters", 2, "The number of perimeters around each island.")
+	flag.Float64Var(&extrusionWidth, "extrusionWidth", 0.4, "The width of the extruded lines.")
+	flag.StringVar(&infillPattern, "infill", "rectilinear", "The infill pattern ("+strings.Join(infill.Names(), ", ")+").")
+	flag.Float64Var(&infillDensity, "infillDensity", 0.2, "The fraction of the inside of the model filled, from 0 to 1.")
+	flag.Float64Var(&infillAngle, "infillAngle", 45, "The angle of the infill lines, in degrees.")
+	flag.IntVar(&topLayers, "topLayers", 3, "The number of solid layers under top surfaces.")
+	flag.IntVar(&bottomLayers, "bottomLayers", 3, "The number of solid layers over bottom surfaces.")
+	flag.StringVar(&gcode, "gcode", "", "The file to write the G-code printing the model to.")
+	flag.Float64Var(&temperature, "temperature", 210, "The temperature of extrusion, in degrees Celsius.")
+	flag.Float64Var(&printSpeed, "printSpeed", 20, "The speed of the head when extruding, in mm/s.")
+	flag.Float64Var(&bedTemperature, "bedTemperature", 0, "The temperature of the bed, in degrees Celsius, 0 for an unheated one.")
+	flag.Float64Var(&chamberTemperature, "chamberTemperature", 0, "The temperature of the chamber, in degrees Celsius, 0 for none.")
+	flag.Float64Var(&fanSpeed, "fanSpeed", 0, "The speed of the part cooling fan after the first layer, from 0 to 1.")
+	flag.StringVar(&profiles, "profiles", "", "The comma separated JSON files of printer and material profiles.")
+	flag.StringVar(&printerProfile, "printer", "", "The printer profile to print with.")
+	flag.StringVar(&materialProfile, "material", "", "The material profile to print with.")
+	flag.StringVar(&settingOverrides, "set", "", "The comma separated key=value printer settings overriding the profiles.")
+	flag.StringVar(&startGCode, "startGCode", "", "The file of the G-code template sent before printing, with placeholders like {temperature}.")
+	flag.StringVar(&endGCode, "endGCode", "", "The file of the G-code template sent after printing.")
+	flag.StringVar(&layerGCode, "layerGCode", "", "The file of the G-code template sent after moving to each layer, with placeholders like {layer_num} and {layer_z}.")
+	flag.StringVar(&svgFile, "svg", "", "The SVG file to draw the layers to.")
+	flag.BoolVar(&svgPerLayer, "svgPerLayer", false, "Whether to draw each layer to its own SVG file, named after the layer index.")
+	flag.BoolVar(&svgToolpaths, "svgToolpaths", false, "Whether to draw the toolpaths over the layers in SVG.")
+	flag.StringVar(&rasterFile, "raster", "", "The zip file to write PNG masks of the layers to, for resin printers.")
+	flag.Float64Var(&pixelSize, "pixelSize", 0.05, "The size of the pixels of the masks, in mm.")
+	flag.IntVar(&antiAliasing, "antiAliasing", 4, "The number of samples along each side of the pixels of the masks, 1 for none.")
+	flag.Float64Var(&exposure, "exposure", 8, "The exposure time of the layers, in seconds.")
+	flag.Float64Var(&bottomExposure, "bottomExposure", 40, "The exposure time of the first layers, in seconds.")
+	flag.IntVar(&bottomExposureLayers, "bottomExposureLayers", 3, "The number of first layers exposed longer.")
 	flag.Parse()
 }
 
 func main() {
+	if debug != "" {
+		text := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+		logger = slog.New(geom.NewSubsystemHandler(text, strings.Split(debug, ",")...))
+	} else {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	}
+
+	settings := loadSettings()
+	layerHeight = *settings.LayerHeight
+
+	config := slicer.DefaultConfig
+	config.Options = slicer.Options{Perimeters: perimeters, LineWidth: extrusionWidth, TopLayers: topLayers, BottomLayers: bottomLayers}
+	config.LayerHeight = layerHeight
+	config.WeldTolerance = float32(weldTolerance)
+	config.Workers = workers
+	config.Infill = infillPattern
+	config.InfillDensity = infillDensity
+	config.InfillAngle = infillAngle
+
+	config.KeepFacets = exportAscii || analyze
+	config.Repair = repair
+	config.Logger = logger
+
 	reader, err := os.Open(filename)
 	check(err)
+	defer reader.Close()
 	parser := stl.NewParser(reader)
-	model, err := parser.Parse()
+	parser.RecomputeNormals = recomputeNormals
+
+	result, err := slicer.Process(context.Background(), parser, &config)
 	check(err)
+	model, layers, regions, toolpaths := result.Model, result.Layers, result.Regions, result.Toolpaths
+
+	if result.Repair != nil {
+		fmt.Printf("repaired: %+v\n", *result.Repair)
+	}
 
-	//	facetsByLayer, segmentsByLayer, minLayer, maxLayer := FacetsByLayer(model, layerHeight)
-	_, segmentsByLayer, minLayer, maxLayer := geom.FacetsByLayer(&model.Facets, layerHeight)
+	if analyze {
+		PrintReport(geom.Analyze(model.Mesh(float32(weldTolerance))))
+	}
 
 	fmt.Println("got slices")
 
-	for i := minLayer; i <= maxLayer; i += 1 {
-		slicename := fmt.Sprintf("%s.%d.svg", filename, i)
-		fmt.Println("writing: ", slicename)
-		// for point, segment := range (*facetsByLayer)[i] {
-		// 	fmt.Printf("  %v: %v\n", point, segment)
-		// }
-		segments := (*segmentsByLayer)[i]
-		for _, segment := range segments {
-			fmt.Printf("  %v -> %v\n", segment.Start, segment.End)
-		}
-		//paths := new([]Path)
-		// paths := PathsFromSegments(segments)
-		//ExportSliceAsSVG(slicename, &segments, paths)
+	if rasterFile != "" {
+		f, err := os.Create(rasterFile)
+		check(err)
+		defer f.Close()
+		options := raster.Options{PixelSize: pixelSize, AntiAliasing: antiAliasing}
+		check(raster.WriteArchive(f, layers, layerHeight, &options, raster.Exposure{Time: exposure, BottomTime: bottomExposure, BottomLayers: bottomExposureLayers}))
+	}
+
+	for i := range regions {
+		PrintRegions(regions[i], toolpaths[i])
+	}
+
+	if svgFile != "" {
+		ExportSVG(svgFile, toolpaths)
+	}
+
+	if gcode != "" {
+		f, err := os.Create(gcode)
+		check(err)
+		defer f.Close()
+		w := bufio.NewWriter(f)
+		slicer.WriteGCode(newPrinter(w, settings), toolpaths, &config)
+		check(w.Flush())
 	}
 
 	if exportAscii {
 		serializer := stl.NewSerializer(os.Stdout)
-		serializer.SerializeAsAscii(filename, model)
+		check(serializer.SerializeAsAscii(filename, model))
 	}
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"regexp"
+	"strings"
 )
 
 type Printer struct {
@@ -18,8 +20,33 @@ type Printer struct {
 	PrintSpeed       float64
 	RetractionSpeed  float64
 	RetractionLength float64
-	Output           io.Writer
-	x, y, z, e       float64
+	// BedTemperature is the temperature of the bed, 0 for an unheated one.
+	BedTemperature float64
+	// FirstLayerTemperature and FirstLayerBedTemperature replace the
+	// temperatures of the nozzle and the bed on the first layer, unless 0.
+	FirstLayerTemperature    float64
+	FirstLayerBedTemperature float64
+	// ChamberTemperature is the temperature of the chamber, 0 for none.
+	ChamberTemperature float64
+	// FanSpeed is the speed of the part cooling fan, from 0 for off to 1
+	// for full speed. The fan is off for the first FanOffLayers layers,
+	// then speeds up to FanSpeed over FanRampLayers layers.
+	FanSpeed      float64
+	FanOffLayers  int
+	FanRampLayers int
+	// StartGCode, EndGCode and LayerGCode are the G-code templates sent
+	// instead of the preamble, after the postamble retraction and after
+	// each layer change, with placeholders replaced as Render does. The
+	// heating commands missing from the start template are sent before
+	// it, and the fan is turned off after it.
+	StartGCode string
+	EndGCode   string
+	LayerGCode string
+	Output     io.Writer
+	x, y, z, e float64
+	layer      int
+	fan        float64
+	fanKnown   bool // whether the fan is known to run at fan
 }
 
 func (p *Printer) SendCommand(format string, args ...interface{}) {
@@ -27,18 +54,63 @@ func (p *Printer) SendCommand(format string, args ...interface{}) {
 }
 
 func (p *Printer) Preamble() {
-	p.SendCommand("G28       ; home all axis")
-	p.SendCommand("G21       ; set units to millimeters")
-	p.SendCommand("G90       ; set absolute coordinates")
-	p.SendCommand("M82       ; use absolute distances for extrusion")
-	p.SetTempAndWait(p.Temperature)
+	if p.StartGCode != "" {
+		start := p.Render(p.StartGCode)
+		p.heat(start)
+		p.SendTemplate(start)
+	} else {
+		p.SendCommand("G28       ; home all axis")
+		p.SendCommand("G21       ; set units to millimeters")
+		p.SendCommand("G90       ; set absolute coordinates")
+		p.SendCommand("M82       ; use absolute distances for extrusion")
+		p.heat("")
+	}
+	p.SetFan(0)
 	p.SendCommand("")
 }
 
+// heatingCommand matches the commands setting temperatures.
+var heatingCommand = regexp.MustCompile(`(?im)^\s*(M104|M109|M140|M190|M141|M191)\b`)
+
+// heat heats the chamber, the bed and the head for the first layer,
+// waiting for them, except for the parts the start G-code already heats.
+func (p *Printer) heat(start string) {
+	sent := make(map[string]bool)
+	for _, match := range heatingCommand.FindAllStringSubmatch(start, -1) {
+		sent[strings.ToUpper(match[1])] = true
+	}
+
+	bed := firstLayer(p.BedTemperature, p.FirstLayerBedTemperature)
+	heatBed := bed > 0 && !sent["M140"] && !sent["M190"]
+	if heatBed {
+		p.SetBedTemp(bed)
+	}
+	if p.ChamberTemperature > 0 && !sent["M141"] && !sent["M191"] {
+		p.SetChamberTempAndWait(p.ChamberTemperature)
+	}
+	if heatBed {
+		p.SetBedTempAndWait(bed)
+	}
+	if !sent["M104"] && !sent["M109"] {
+		p.SetTempAndWait(firstLayer(p.Temperature, p.FirstLayerTemperature))
+	}
+}
+
 func (p *Printer) Postamble() {
 	p.retract()
+	p.SetFan(0)
 	p.SendCommand("")
+	if p.EndGCode != "" {
+		p.SendTemplate(p.EndGCode)
+		return
+	}
 	p.SendCommand("M104 S0 ; turn off temperature")
+	if firstLayer(p.BedTemperature, p.FirstLayerBedTemperature) > 0 {
+		p.SetBedTemp(0)
+	}
+	if p.ChamberTemperature > 0 {
+		p.SetChamberTemp(0)
+	}
 	p.SendCommand("G28 X0  ; home X axis")
 	p.SendCommand("M84     ; turn off motors")
 }
@@ -57,16 +129,101 @@ func (p *Printer) SetTempAndWait(temp float64) {
 	p.SendCommand("M109 S%.3f ; set and wait head temperature", temp)
 }
 
+// SetTemp sets the temperature of the head without waiting for it.
+func (p *Printer) SetTemp(temp float64) {
+	p.SendCommand("M104 S%.3f ; set head temperature", temp)
+}
+
+// SetBedTemp sets the temperature of the bed without waiting for it.
+func (p *Printer) SetBedTemp(temp float64) {
+	p.SendCommand("M140 S%.3f ; set bed temperature", temp)
+}
+
+// SetBedTempAndWait sets the temperature of the bed and waits for it.
+func (p *Printer) SetBedTempAndWait(temp float64) {
+	p.SendCommand("M190 S%.3f ; set and wait bed temperature", temp)
+}
+
+// SetChamberTemp sets the temperature of the chamber without waiting for
+// it.
+func (p *Printer) SetChamberTemp(temp float64) {
+	p.SendCommand("M141 S%.3f ; set chamber temperature", temp)
+}
+
+// SetChamberTempAndWait sets the temperature of the chamber and waits for
+// it.
+func (p *Printer) SetChamberTempAndWait(temp float64) {
+	p.SendCommand("M191 S%.3f ; set and wait chamber temperature", temp)
+}
+
+// SetFan sets the speed of the part cooling fan, from 0 for off to 1 for
+// full speed, unless it is known to run at that speed already.
+func (p *Printer) SetFan(speed float64) {
+	speed = math.Max(0, math.Min(1, speed))
+	if p.fanKnown && speed == p.fan {
+		return
+	}
+	p.fan, p.fanKnown = speed, true
+	if speed == 0 {
+		p.SendCommand("M107      ; turn off fan")
+		return
+	}
+	p.SendCommand("M106 S%d ; set fan speed", int(math.Round(255*speed)))
+}
+
+// FanSpeedAt returns the speed of the fan on the layer with the given
+// number, counting from 0.
+func (p *Printer) FanSpeedAt(layer int) float64 {
+	if layer < p.FanOffLayers {
+		return 0
+	}
+	ramp := float64(layer-p.FanOffLayers+1) / float64(p.FanRampLayers+1)
+	return p.FanSpeed * math.Min(1, ramp)
+}
+
+// firstLayer returns the temperature on the first layer, which replaces
+// the one on the others unless 0.
+func firstLayer(temp, first float64) float64 {
+	if first > 0 {
+		return first
+	}
+	return temp
+}
+
 func (p *Printer) Raise() {
-	p.z += p.LayerHeight
-	p.SendCommand("G0 Z%.3f F%.3f ; raise", p.z, 60*p.TravelSpeed)
+	p.MoveZ(p.z + p.LayerHeight)
+}
+
+// MoveZ moves the head to the height z, to print the layer there.
+func (p *Printer) MoveZ(z float64) {
+	p.z = z
+	p.SendCommand("G0 Z%.3f F%.3f ; raise", p.z, p.feedRate(p.TravelSpeed))
 	p.ZeroExtrusion()
 }
 
+// LayerChange moves the head to the height z to print the layer with the
+// given number, counting from 0, then sends the layer change template.
+// Leaving the first layer, the temperatures change to the ones of the
+// other layers, and the fan follows its ramp throughout.
+func (p *Printer) LayerChange(layer int, z float64) {
+	p.layer = layer
+	p.MoveZ(z)
+	p.SendTemplate(p.LayerGCode)
+	if layer == 1 {
+		if p.FirstLayerTemperature > 0 && p.FirstLayerTemperature != p.Temperature {
+			p.SetTemp(p.Temperature)
+		}
+		if p.FirstLayerBedTemperature > 0 && p.FirstLayerBedTemperature != p.BedTemperature {
+			p.SetBedTemp(p.BedTemperature)
+		}
+	}
+	p.SetFan(p.FanSpeedAt(layer))
+}
+
 func (p *Printer) Move(x, y float64) {
 	p.x = x + p.CenterX
 	p.y = y + p.CenterY
-	p.SendCommand("G0 X%.3f Y%.3f F%.3f ; move", p.x, p.y, 60*p.TravelSpeed)
+	p.SendCommand("G0 X%.3f Y%.3f F%.3f ; move", p.x, p.y, p.feedRate(p.TravelSpeed))
 }
 
 func (p *Printer) MoveAndRetract(x, y float64) {
@@ -83,13 +240,30 @@ func (p *Printer) Print(x, y, spread float64) {
 	p.x = tx
 	p.y = ty
 	p.e += p.getExtrusionLength(p.linearDistance(dx, dy), spread)
-	p.SendCommand("G0 X%.3f Y%.3f E%.3f F%.3f ; print", p.x, p.y, p.e, 60*p.PrintSpeed)
+	p.SendCommand("G0 X%.3f Y%.3f E%.3f F%.3f ; print", p.x, p.y, p.e, p.feedRate(p.PrintSpeed))
+}
+
+// Spread returns the extrusion spread giving lines of the given width. The
+// lines are squashed into a rectangle as high as a layer with round ends.
+func (p *Printer) Spread(width float64) float64 {
+	h := p.LayerHeight
+	area := h*(width-h) + math.Pi*h*h/4
+	return area/(h*h) - math.Pi/2
 }
 
 func (p *Printer) getExtrusionLength(d, spread float64) float64 {
 	return 4 * p.FlowCorrection * p.LayerHeight * p.LayerHeight * d * (spread + math.Pi/2) / (math.Pi * p.FilamentDiameter * p.FilamentDiameter)
 }
 
+// feedRate returns the feed rate of G-code moves, in mm/min, for a speed of
+// the head in mm/s, limited to the maximum speed of the printer if known.
+func (p *Printer) feedRate(speed float64) float64 {
+	if p.MaxSpeed > 0 && speed > p.MaxSpeed {
+		speed = p.MaxSpeed
+	}
+	return 60 * speed
+}
+
 func (p *Printer) linearDistance(dx, dy float64) float64 {
 	return math.Sqrt(dx*dx + dy*dy)
 }
@@ -1,7 +1,9 @@
 package printer
 
 import (
+	"bytes"
 	"math"
+	"strings"
 	"testing"
 )
 
@@ -15,3 +17,141 @@ func TestLinearDistance(t *testing.T) {
 		t.Error("wrong distance")
 	}
 }
+
+func TestSpread(t *testing.T) {
+	p := Printer{LayerHeight: 0.2, FlowCorrection: 1, FilamentDiameter: 1.75}
+
+	// a line 0.4 wide and 0.2 high, 10 long
+	area := 0.2*0.2 + math.Pi*0.01
+	expected := 10 * area / (math.Pi * 1.75 * 1.75 / 4)
+	if e := p.getExtrusionLength(10, p.Spread(0.4)); math.Abs(e-expected) > 1e-9 {
+		t.Errorf("Expected %v of filament, got %v", expected, e)
+	}
+}
+
+func TestMoveZ(t *testing.T) {
+	var b bytes.Buffer
+	p := Printer{LayerHeight: 0.2, TravelSpeed: 100, Output: &b}
+
+	p.MoveZ(0.3)
+	p.Raise()
+
+	expected := "G0 Z0.300 F6000.000 ; raise\nG92 E0    ; zero extrusion\nG0 Z0.500 F6000.000 ; raise\nG92 E0    ; zero extrusion\n"
+	if b.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, b.String())
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	var b bytes.Buffer
+	p := Printer{
+		Temperature:    215,
+		BedTemperature: 60,
+		LayerHeight:    0.2,
+		TravelSpeed:    100,
+		StartGCode:     "M190 S{bed_temperature}\nM109 S{temperature}\nG28 {unknown}\n",
+		LayerGCode:     "; layer {layer_num} at {layer_z}",
+		EndGCode:       "M84",
+		Output:         &b,
+	}
+
+	p.Preamble()
+	p.LayerChange(1, 0.4)
+	p.Postamble()
+
+	expected := "M190 S60\nM109 S215\nG28 {unknown}\nM107      ; turn off fan\n\n" +
+		"G0 Z0.400 F6000.000 ; raise\nG92 E0    ; zero extrusion\n; layer 1 at 0.4\n" +
+		"G0 E0.000 F0.000 ; retract\n\nM84\n"
+	if b.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, b.String())
+	}
+
+	if err := CheckTemplate("G1 Z{layer_z}"); err != nil {
+		t.Errorf("Expected a valid template, got %v", err)
+	}
+	if err := CheckTemplate("G1 Z{z}"); err == nil {
+		t.Error("Expected an error for an unknown placeholder")
+	}
+}
+
+func TestTemperaturesAndFan(t *testing.T) {
+	var b bytes.Buffer
+	p := Printer{
+		Temperature:              200,
+		FirstLayerTemperature:    210,
+		BedTemperature:           60,
+		FirstLayerBedTemperature: 70,
+		ChamberTemperature:       40,
+		FanSpeed:                 0.8,
+		FanOffLayers:             1,
+		FanRampLayers:            1,
+		LayerHeight:              0.2,
+		Output:                   &b,
+	}
+
+	p.Preamble()
+	for layer := 0; layer < 4; layer++ {
+		p.LayerChange(layer, float64(layer+1)*p.LayerHeight)
+	}
+	p.Postamble()
+
+	var commands []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if strings.HasPrefix(line, "M1") {
+			commands = append(commands, strings.Fields(line)[0]+" "+strings.Fields(line)[1])
+		}
+	}
+	expected := []string{
+		"M140 S70.000", "M191 S40.000", "M190 S70.000", "M109 S210.000", "M107 ;", // first layer
+		"M104 S200.000", "M140 S60.000", "M106 S102", // second layer, fan half way up
+		"M106 S204", // third layer, fan at full speed
+		"M107 ;", "M104 S0", "M140 S0.000", "M141 S0.000",
+	}
+	if strings.Join(commands, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("Expected %v, got %v", expected, commands)
+	}
+}
+
+func TestFanSpeedAt(t *testing.T) {
+	p := Printer{FanSpeed: 1, FanOffLayers: 2, FanRampLayers: 3}
+	for layer, expected := range []float64{0, 0, 0.25, 0.5, 0.75, 1, 1} {
+		if speed := p.FanSpeedAt(layer); speed != expected {
+			t.Errorf("Expected fan speed %v on layer %v, got %v", expected, layer, speed)
+		}
+	}
+}
+
+func TestMaxSpeed(t *testing.T) {
+	var b bytes.Buffer
+	p := Printer{TravelSpeed: 200, PrintSpeed: 50, MaxSpeed: 100, Output: &b}
+
+	p.Move(1, 1)
+	p.Print(2, 2, 1)
+
+	// travel is limited to the maximum speed, printing is slower already
+	if !strings.Contains(b.String(), "F6000.000 ; move") || !strings.Contains(b.String(), "F3000.000 ; print") {
+		t.Errorf("Expected moves at 100mm/s and prints at 50mm/s, got %q", b.String())
+	}
+}
+
+func TestStartTemplateHeating(t *testing.T) {
+	var b bytes.Buffer
+	p := Printer{
+		Temperature:        200,
+		BedTemperature:     60,
+		ChamberTemperature: 40,
+		StartGCode:         "G28\nm190 S{bed_temperature} ; the bed only",
+		Output:             &b,
+	}
+
+	p.Preamble()
+
+	// the chamber and the head are heated before the template, which
+	// heats the bed itself
+	expected := "M191 S40.000 ; set and wait chamber temperature\n" +
+		"M109 S200.000 ; set and wait head temperature\n" +
+		"G28\nm190 S60 ; the bed only\nM107      ; turn off fan\n\n"
+	if b.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, b.String())
+	}
+}
@@ -0,0 +1,388 @@
+package printer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Settings configure a Printer. Unset settings are nil, so that profiles
+// can set only the ones they change from the profiles they inherit from.
+type Settings struct {
+	LayerHeight      *float64 `json:"layer_height,omitempty"`
+	FlowCorrection   *float64 `json:"flow_correction,omitempty"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+	MaxSpeed         *float64 `json:"max_speed,omitempty"`
+	CenterX          *float64 `json:"center_x,omitempty"`
+	CenterY          *float64 `json:"center_y,omitempty"`
+	FilamentDiameter *float64 `json:"filament_diameter,omitempty"`
+	TravelSpeed      *float64 `json:"travel_speed,omitempty"`
+	PrintSpeed       *float64 `json:"print_speed,omitempty"`
+	RetractionSpeed  *float64 `json:"retraction_speed,omitempty"`
+	RetractionLength *float64 `json:"retraction_length,omitempty"`
+	BedTemperature   *float64 `json:"bed_temperature,omitempty"`
+	StartGCode       *string  `json:"start_gcode,omitempty"`
+	EndGCode         *string  `json:"end_gcode,omitempty"`
+	LayerGCode       *string  `json:"layer_gcode,omitempty"`
+
+	FirstLayerTemperature    *float64 `json:"first_layer_temperature,omitempty"`
+	FirstLayerBedTemperature *float64 `json:"first_layer_bed_temperature,omitempty"`
+	ChamberTemperature       *float64 `json:"chamber_temperature,omitempty"`
+	FanSpeed                 *float64 `json:"fan_speed,omitempty"`
+	FanOffLayers             *int     `json:"fan_off_layers,omitempty"`
+	FanRampLayers            *int     `json:"fan_ramp_layers,omitempty"`
+}
+
+// limits are the valid ranges of the settings, by key.
+var limits = map[string]struct{ min, max float64 }{
+	"layer_height":      {0.01, 2},
+	"flow_correction":   {0.1, 3},
+	"temperature":       {0, 500},
+	"max_speed":         {1, 2000},
+	"center_x":          {0, 2000},
+	"center_y":          {0, 2000},
+	"filament_diameter": {0.5, 5},
+	"travel_speed":      {1, 2000},
+	"print_speed":       {1, 2000},
+	"retraction_speed":  {1, 500},
+	"retraction_length": {0, 20},
+	"bed_temperature":   {0, 150},
+
+	"first_layer_temperature":     {0, 500},
+	"first_layer_bed_temperature": {0, 150},
+	"chamber_temperature":         {0, 100},
+	"fan_speed":                   {0, 1},
+	"fan_off_layers":              {0, 1000},
+	"fan_ramp_layers":             {0, 1000},
+}
+
+// DefaultSettings are the settings every profile starts from.
+func DefaultSettings() Settings {
+	value := func(v float64) *float64 { return &v }
+	none := func() *string { return new(string) }
+	count := func(n int) *int { return &n }
+	return Settings{
+		LayerHeight:      value(0.2),
+		FlowCorrection:   value(1),
+		Temperature:      value(210),
+		MaxSpeed:         value(150),
+		CenterX:          value(175),
+		CenterY:          value(100),
+		FilamentDiameter: value(2.85),
+		TravelSpeed:      value(150),
+		PrintSpeed:       value(20),
+		RetractionSpeed:  value(20),
+		RetractionLength: value(2),
+		BedTemperature:   value(0),
+		StartGCode:       none(),
+		EndGCode:         none(),
+		LayerGCode:       none(),
+
+		FirstLayerTemperature:    value(0),
+		FirstLayerBedTemperature: value(0),
+		ChamberTemperature:       value(0),
+		FanSpeed:                 value(0),
+		FanOffLayers:             count(1),
+		FanRampLayers:            count(0),
+	}
+}
+
+// fields calls fn with the key and the value of every setting.
+func (s *Settings) fields(fn func(key string, field reflect.Value)) {
+	v := reflect.ValueOf(s).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		key := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		fn(key, v.Field(i))
+	}
+}
+
+// Merge sets the settings set in o, overriding the ones in s.
+func (s *Settings) Merge(o *Settings) {
+	ov := reflect.ValueOf(o).Elem()
+	i := 0
+	s.fields(func(key string, field reflect.Value) {
+		if f := ov.Field(i); !f.IsNil() {
+			field.Set(f)
+		}
+		i++
+	})
+}
+
+// Set sets the setting with the given key, as found in profile files,
+// from its text.
+func (s *Settings) Set(key, value string) error {
+	found := false
+	var err error
+	s.fields(func(k string, field reflect.Value) {
+		if k != key {
+			return
+		}
+		found = true
+		switch field.Type().Elem().Kind() {
+		case reflect.String:
+			field.Set(reflect.ValueOf(&value))
+		case reflect.Int:
+			var v int
+			if v, err = strconv.Atoi(value); err == nil {
+				field.Set(reflect.ValueOf(&v))
+			}
+		default:
+			var v float64
+			if v, err = strconv.ParseFloat(value, 64); err == nil {
+				field.Set(reflect.ValueOf(&v))
+			}
+		}
+	})
+	if !found {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("setting %q: %v", key, err)
+	}
+	return nil
+}
+
+// SetAll sets the settings assigned in comma separated key=value pairs.
+func (s *Settings) SetAll(assignments string) error {
+	for _, assignment := range strings.Split(assignments, ",") {
+		if strings.TrimSpace(assignment) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			return fmt.Errorf("expected key=value, got %q", assignment)
+		}
+		if err := s.Set(strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SettingError is a setting missing, out of its range or, for G-code
+// templates, invalid.
+type SettingError struct {
+	Key      string
+	Value    float64
+	Min, Max float64
+	Missing  bool
+	Err      error
+}
+
+func (e *SettingError) Error() string {
+	if e.Missing {
+		return fmt.Sprintf("setting %q is missing", e.Key)
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("setting %q: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("setting %q is %v, out of the range from %v to %v", e.Key, e.Value, e.Min, e.Max)
+}
+
+// Validate checks that all the settings are set and within their ranges,
+// returning a SettingError for each of those which aren't.
+func (s *Settings) Validate() error {
+	var errs []error
+	s.fields(func(key string, field reflect.Value) {
+		l, ok := limits[key]
+		switch {
+		case field.IsNil():
+			errs = append(errs, &SettingError{Key: key, Missing: true})
+		case field.Type().Elem().Kind() == reflect.String:
+			if err := CheckTemplate(field.Elem().String()); err != nil {
+				errs = append(errs, &SettingError{Key: key, Err: err})
+			}
+		case !ok:
+		default:
+			var v float64
+			if field.Type().Elem().Kind() == reflect.Int {
+				v = float64(field.Elem().Int())
+			} else {
+				v = field.Elem().Float()
+			}
+			if math.IsNaN(v) || v < l.min || v > l.max {
+				errs = append(errs, &SettingError{Key: key, Value: v, Min: l.min, Max: l.max})
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// Apply configures the printer with the settings which are set.
+func (s *Settings) Apply(p *Printer) {
+	for _, f := range []struct {
+		setting *float64
+		field   *float64
+	}{
+		{s.LayerHeight, &p.LayerHeight},
+		{s.FlowCorrection, &p.FlowCorrection},
+		{s.Temperature, &p.Temperature},
+		{s.MaxSpeed, &p.MaxSpeed},
+		{s.CenterX, &p.CenterX},
+		{s.CenterY, &p.CenterY},
+		{s.FilamentDiameter, &p.FilamentDiameter},
+		{s.TravelSpeed, &p.TravelSpeed},
+		{s.PrintSpeed, &p.PrintSpeed},
+		{s.RetractionSpeed, &p.RetractionSpeed},
+		{s.RetractionLength, &p.RetractionLength},
+		{s.BedTemperature, &p.BedTemperature},
+		{s.FirstLayerTemperature, &p.FirstLayerTemperature},
+		{s.FirstLayerBedTemperature, &p.FirstLayerBedTemperature},
+		{s.ChamberTemperature, &p.ChamberTemperature},
+		{s.FanSpeed, &p.FanSpeed},
+	} {
+		if f.setting != nil {
+			*f.field = *f.setting
+		}
+	}
+	for _, f := range []struct {
+		setting *string
+		field   *string
+	}{
+		{s.StartGCode, &p.StartGCode},
+		{s.EndGCode, &p.EndGCode},
+		{s.LayerGCode, &p.LayerGCode},
+	} {
+		if f.setting != nil {
+			*f.field = *f.setting
+		}
+	}
+	if s.FanOffLayers != nil {
+		p.FanOffLayers = *s.FanOffLayers
+	}
+	if s.FanRampLayers != nil {
+		p.FanRampLayers = *s.FanRampLayers
+	}
+}
+
+// Profile is a named set of settings, changing the ones of the profile it
+// inherits from, if any.
+type Profile struct {
+	Inherits string `json:"inherits,omitempty"`
+	Settings
+}
+
+// Profiles are the printer and material profiles, by name. Material
+// profiles override the settings of printer profiles.
+type Profiles struct {
+	Printers  map[string]*Profile `json:"printers"`
+	Materials map[string]*Profile `json:"materials"`
+}
+
+// LoadProfiles reads profiles in JSON. Unknown settings are errors.
+func LoadProfiles(r io.Reader) (*Profiles, error) {
+	profiles := new(Profiles)
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// LoadProfileFiles reads profiles from files. Profiles in later files
+// replace the ones with the same name in earlier files.
+func LoadProfileFiles(filenames ...string) (*Profiles, error) {
+	all := &Profiles{Printers: make(map[string]*Profile), Materials: make(map[string]*Profile)}
+	for _, filename := range filenames {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		profiles, err := LoadProfiles(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		for name, p := range profiles.Printers {
+			all.Printers[name] = p
+		}
+		for name, p := range profiles.Materials {
+			all.Materials[name] = p
+		}
+	}
+	return all, nil
+}
+
+// Resolve returns the settings of a printer with a material, either of
+// which may be empty for none, on top of the default settings.
+func (p *Profiles) Resolve(printer, material string) (*Settings, error) {
+	settings := DefaultSettings()
+	for _, r := range []struct {
+		kind     string
+		profiles map[string]*Profile
+		name     string
+	}{{"printer", p.Printers, printer}, {"material", p.Materials, material}} {
+		if r.name == "" {
+			continue
+		}
+		chain, err := inheritance(r.kind, r.profiles, r.name)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(chain) - 1; i >= 0; i-- {
+			settings.Merge(&chain[i].Settings)
+		}
+	}
+	return &settings, nil
+}
+
+// inheritance returns a profile followed by the ones it inherits from.
+func inheritance(kind string, profiles map[string]*Profile, name string) ([]*Profile, error) {
+	var chain []*Profile
+	seen := make(map[string]bool)
+	for name != "" {
+		if seen[name] {
+			return nil, fmt.Errorf("%s profile %q inherits from itself", kind, name)
+		}
+		seen[name] = true
+		profile, ok := profiles[name]
+		if !ok && len(profiles) == 0 {
+			return nil, fmt.Errorf("unknown %s profile %q, no %s profiles loaded", kind, name, kind)
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown %s profile %q, expected one of %s", kind, name, strings.Join(names(profiles), ", "))
+		}
+		chain = append(chain, profile)
+		name = profile.Inherits
+	}
+	return chain, nil
+}
+
+// names returns the sorted names of the profiles.
+func names(profiles map[string]*Profile) []string {
+	var n []string
+	for name := range profiles {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	return n
+}
+
+// LoadSettings resolves the settings of a printer with a material from
+// profile files, as Resolve does, then overrides them with the comma
+// separated key=value assignments and validates the result.
+func LoadSettings(filenames []string, printer, material, overrides string) (*Settings, error) {
+	profiles, err := LoadProfileFiles(filenames...)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := profiles.Resolve(printer, material)
+	if err != nil {
+		return nil, err
+	}
+	if err := settings.SetAll(overrides); err != nil {
+		return nil, err
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
@@ -0,0 +1,100 @@
+package printer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResolveProfiles(t *testing.T) {
+	settings, err := LoadSettings([]string{"testdata/profiles.json"}, "ultimaker-fine", "petg", "print_speed=25, retraction_length=1.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p Printer
+	settings.Apply(&p)
+	expected := Printer{
+		LayerHeight:              0.1,  // printer, overriding the default
+		FlowCorrection:           0.95, // material, overriding its parent
+		Temperature:              240,  // material, overriding its parent
+		MaxSpeed:                 300,  // inherited from the parent printer
+		CenterX:                  100,
+		CenterY:                  100,
+		FilamentDiameter:         2.85,
+		TravelSpeed:              150,
+		PrintSpeed:               25, // overridden
+		RetractionSpeed:          20,
+		RetractionLength:         1.5, // overridden
+		BedTemperature:           70,
+		FirstLayerTemperature:    235,
+		FirstLayerBedTemperature: 80,
+		FanSpeed:                 0.5,
+		FanOffLayers:             1,
+		FanRampLayers:            2, // inherited from the parent material
+		StartGCode:               "M190 S{first_layer_bed_temperature}\nM109 S{first_layer_temperature}\nG28\nG21\nG90\nM82\nG92 E0\nG1 Y5 E10 F600 ; purge",
+		EndGCode:                 "M104 S0\nM140 S0\nG28 X0 Y0\nM84",
+	}
+	if p != expected {
+		t.Errorf("Expected %+v, got %+v", expected, p)
+	}
+}
+
+func TestResolveDefaults(t *testing.T) {
+	settings, err := new(Profiles).Resolve("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := settings.Validate(); err != nil {
+		t.Errorf("Expected valid default settings, got %v", err)
+	}
+}
+
+func TestProfileErrors(t *testing.T) {
+	for _, c := range []struct {
+		profiles string
+		printer  string
+		expected string
+	}{
+		{`{"printers": {"a": {"inherits": "b"}, "b": {"inherits": "a"}}}`, "a", "inherits from itself"},
+		{`{"printers": {"a": {"inherits": "b"}}}`, "a", `unknown printer profile "b"`},
+		{`{"printers": {"a": {}}}`, "c", `expected one of a`},
+		{`{"printers": {"a": {"nozzle": 1}}}`, "a", `unknown field "nozzle"`},
+	} {
+		profiles, err := LoadProfiles(strings.NewReader(c.profiles))
+		if err == nil {
+			_, err = profiles.Resolve(c.printer, "")
+		}
+		if err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Errorf("Expected an error with %q, got %v", c.expected, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	settings := DefaultSettings()
+	settings.CenterX = nil
+	if err := settings.SetAll("temperature=600,layer_height=0,layer_gcode=G1 Z{z}"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := settings.Validate()
+	invalid := make(map[string]bool)
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var s *SettingError
+		if !errors.As(e, &s) {
+			t.Fatalf("Expected a setting error, got %v", e)
+		}
+		invalid[s.Key] = true
+	}
+	if len(invalid) != 4 || !invalid["temperature"] || !invalid["layer_height"] || !invalid["center_x"] || !invalid["layer_gcode"] {
+		t.Errorf("Expected temperature, layer_height, center_x and layer_gcode to be invalid, got %v", err)
+	}
+
+	if err := settings.SetAll("nozzle=1"); err == nil {
+		t.Error("Expected an error setting an unknown setting")
+	}
+	if err := settings.SetAll("temperature"); err == nil {
+		t.Error("Expected an error for an assignment without value")
+	}
+}
@@ -0,0 +1,67 @@
+package printer
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// placeholder matches the placeholders of G-code templates, like {layer_z}.
+var placeholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// variables returns the values of the placeholders of G-code templates.
+func (p *Printer) variables() map[string]float64 {
+	return map[string]float64{
+		"temperature":                 p.Temperature,
+		"bed_temperature":             p.BedTemperature,
+		"first_layer_temperature":     firstLayer(p.Temperature, p.FirstLayerTemperature),
+		"first_layer_bed_temperature": firstLayer(p.BedTemperature, p.FirstLayerBedTemperature),
+		"chamber_temperature":         p.ChamberTemperature,
+		"fan_speed":                   p.FanSpeed,
+		"layer_num":                   float64(p.layer),
+		"layer_z":                     p.z,
+		"layer_height":                p.LayerHeight,
+		"filament_diameter":           p.FilamentDiameter,
+		"travel_speed":                p.TravelSpeed,
+		"print_speed":                 p.PrintSpeed,
+		"retraction_speed":            p.RetractionSpeed,
+		"retraction_length":           p.RetractionLength,
+		"center_x":                    p.CenterX,
+		"center_y":                    p.CenterY,
+	}
+}
+
+// CheckTemplate returns an error if the G-code template has placeholders
+// the printer doesn't know about.
+func CheckTemplate(template string) error {
+	variables := new(Printer).variables()
+	for _, match := range placeholder.FindAllStringSubmatch(template, -1) {
+		if _, ok := variables[match[1]]; !ok {
+			return fmt.Errorf("unknown placeholder %s", match[0])
+		}
+	}
+	return nil
+}
+
+// Render replaces the placeholders of the G-code template with their
+// current values, rounded to a thousandth. Unknown placeholders are left
+// as they are.
+func (p *Printer) Render(template string) string {
+	variables := p.variables()
+	return placeholder.ReplaceAllStringFunc(template, func(match string) string {
+		v, ok := variables[strings.Trim(match, "{}")]
+		if !ok {
+			return match
+		}
+		return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+	})
+}
+
+// SendTemplate renders the G-code template and sends it.
+func (p *Printer) SendTemplate(template string) {
+	if template = strings.TrimRight(p.Render(template), "\n"); template != "" {
+		p.SendCommand("%s", template)
+	}
+}
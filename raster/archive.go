@@ -0,0 +1,98 @@
+package raster
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"github.com/stefanom/peano/geom"
+	"image/png"
+	"io"
+)
+
+// ManifestName is the name of the manifest in archives.
+const ManifestName = "manifest.json"
+
+// Exposure is how long the layers are cured, in seconds. The first layers
+// are exposed longer, to stick to the build plate.
+type Exposure struct {
+	Time         float64 `json:"exposureTime"`
+	BottomTime   float64 `json:"bottomExposureTime"`
+	BottomLayers int     `json:"bottomLayers"`
+}
+
+// Manifest describes the images in an archive.
+type Manifest struct {
+	LayerHeight float64 `json:"layerHeight"`
+	PixelSize   float64 `json:"pixelSize"`
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	Exposure
+	Layers []LayerEntry `json:"layers"`
+}
+
+// LayerEntry describes the image of a layer.
+type LayerEntry struct {
+	File     string  `json:"file"`
+	Index    int32   `json:"index"`
+	Z        float64 `json:"z"`
+	Exposure float64 `json:"exposureTime"`
+	// Area is the area of the layer, in square millimeters, from which
+	// the volume of resin used can be found.
+	Area float64 `json:"area"`
+}
+
+// WriteArchive writes a zip archive with the manifest and a PNG image of
+// each layer, in order. It fails if the pixel size is not positive or the
+// image size is negative.
+func WriteArchive(w io.Writer, layers []*geom.Layer, layerHeight float64, options *Options, exposure Exposure) error {
+	if options.PixelSize <= 0 {
+		return fmt.Errorf("raster: invalid pixel size %v", options.PixelSize)
+	}
+	if options.Width < 0 || options.Height < 0 {
+		return fmt.Errorf("raster: invalid image size %vx%v", options.Width, options.Height)
+	}
+	frame := NewFrame(layers, options)
+	manifest := Manifest{
+		LayerHeight: layerHeight,
+		PixelSize:   options.PixelSize,
+		Width:       frame.Width,
+		Height:      frame.Height,
+		Exposure:    exposure,
+		Layers:      make([]LayerEntry, len(layers)),
+	}
+	for i, l := range layers {
+		manifest.Layers[i] = LayerEntry{
+			File:     fmt.Sprintf("layer-%05d.png", i),
+			Index:    l.Index,
+			Z:        l.Z,
+			Exposure: exposure.Time,
+			Area:     l.Area(),
+		}
+		if i < exposure.BottomLayers {
+			manifest.Layers[i].Exposure = exposure.BottomTime
+		}
+	}
+
+	archive := zip.NewWriter(w)
+	f, err := archive.Create(ManifestName)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(&manifest); err != nil {
+		return err
+	}
+
+	for i, l := range layers {
+		// PNG images are compressed already.
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: manifest.Layers[i].File, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(f, Draw(l, frame, options)); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
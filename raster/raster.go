@@ -0,0 +1,175 @@
+// Package raster draws the layers of a sliced model as grayscale bitmaps,
+// the masks cured layer by layer by resin printers.
+package raster
+
+import (
+	"github.com/stefanom/peano/geom"
+	"image"
+	"math"
+	"sort"
+)
+
+// Options control how layers are drawn.
+type Options struct {
+	// PixelSize is the size of a pixel, in millimeters.
+	PixelSize float64
+	// Width and Height are the size of the images, in pixels, usually the
+	// resolution of the screen of the printer. When 0, the images are just
+	// large enough for the model.
+	Width, Height int
+	// AntiAliasing is the number of samples taken along each side of a
+	// pixel to find how much of it is covered, or 1 for pixels which are
+	// either fully on or off.
+	AntiAliasing int
+	// Mirror flips the images left to right, for printers looking at the
+	// screen from below.
+	Mirror bool
+}
+
+// DefaultOptions are for a 50 micron screen, sized to the model, with
+// 4x4 anti-aliasing.
+var DefaultOptions = Options{
+	PixelSize:    0.05,
+	AntiAliasing: 4,
+}
+
+// Frame maps millimeters to pixels: the center of the model is at the
+// center of the images.
+type Frame struct {
+	Width, Height int
+	// originX and originY are the coordinates of the top left corner of
+	// the images, in millimeters.
+	originX, originY float64
+	pixelSize        float64
+	mirror           bool
+}
+
+// NewFrame returns the frame drawing the layers in images of the size
+// given by the options, or just containing the model if there is none.
+// The pixel size must be positive.
+func NewFrame(layers []*geom.Layer, options *Options) *Frame {
+	b := (&geom.Layer{}).Bounds()
+	for _, l := range layers {
+		b = b.Union(l.Bounds())
+	}
+	if b.Empty() {
+		b = geom.Bounds{}
+	}
+
+	f := &Frame{Width: options.Width, Height: options.Height, pixelSize: options.PixelSize, mirror: options.Mirror}
+	if f.Width <= 0 {
+		f.Width = int(math.Ceil(float64(b.Max[0]-b.Min[0])/options.PixelSize)) + 2
+	}
+	if f.Height <= 0 {
+		f.Height = int(math.Ceil(float64(b.Max[1]-b.Min[1])/options.PixelSize)) + 2
+	}
+	f.originX = float64(b.Min[0]+b.Max[0])/2 - float64(f.Width)*options.PixelSize/2
+	f.originY = float64(b.Min[1]+b.Max[1])/2 + float64(f.Height)*options.PixelSize/2
+	return f
+}
+
+// pixel returns the position of a point in pixels, from the top left
+// corner of the images.
+func (f *Frame) pixel(p geom.Point) (x, y float64) {
+	x = (float64(p[0]) - f.originX) / f.pixelSize
+	y = (f.originY - float64(p[1])) / f.pixelSize
+	if f.mirror {
+		x = float64(f.Width) - x
+	}
+	return
+}
+
+// edge is an edge of the islands, in pixels, going from its top end down.
+type edge struct {
+	x0, y0, x1, y1 float64
+}
+
+// Draw returns the image of the layer: white where it is solid, black
+// elsewhere, and gray on the edges with anti-aliasing.
+func Draw(layer *geom.Layer, frame *Frame, options *Options) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, frame.Width, frame.Height))
+
+	var edges []edge
+	for i := range layer.Islands {
+		for _, path := range layer.Islands[i].Paths() {
+			for j := range path {
+				x0, y0 := frame.pixel(path[j])
+				x1, y1 := frame.pixel(path[(j+1)%len(path)])
+				if y0 == y1 {
+					continue
+				}
+				if y0 > y1 {
+					x0, y0, x1, y1 = x1, y1, x0, y0
+				}
+				edges = append(edges, edge{x0, y0, x1, y1})
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].y0 < edges[j].y0 })
+
+	samples := options.AntiAliasing
+	if samples < 1 {
+		samples = 1
+	}
+	coverage := make([]float64, frame.Width)
+	var crossings []float64
+	var active []edge
+	next := 0
+
+	for row := 0; row < frame.Height; row++ {
+		for i := range coverage {
+			coverage[i] = 0
+		}
+
+		for s := 0; s < samples; s++ {
+			// scan the row at the middle of each sample
+			y := float64(row) + (float64(s)+0.5)/float64(samples)
+			for next < len(edges) && edges[next].y0 <= y {
+				active = append(active, edges[next])
+				next++
+			}
+			kept := active[:0]
+			crossings = crossings[:0]
+			for _, e := range active {
+				if e.y1 <= y {
+					continue
+				}
+				kept = append(kept, e)
+				if e.y0 <= y {
+					crossings = append(crossings, e.x0+(y-e.y0)*(e.x1-e.x0)/(e.y1-e.y0))
+				}
+			}
+			active = kept
+			sort.Float64s(crossings)
+
+			// The islands are inside an odd number of crossings.
+			for i := 0; i+1 < len(crossings); i += 2 {
+				cover(coverage, crossings[i], crossings[i+1], samples)
+			}
+		}
+
+		for x, c := range coverage {
+			img.Pix[row*img.Stride+x] = uint8(math.Round(255 * math.Min(c/float64(samples), 1)))
+		}
+	}
+	return img
+}
+
+// cover adds the span from x0 to x1, in pixels, to the coverage of the
+// pixels of a row. With anti-aliasing, pixels are partially covered by the
+// ends of the span, otherwise they are covered when their centers are.
+func cover(coverage []float64, x0, x1 float64, samples int) {
+	if samples == 1 {
+		from := int(math.Max(math.Ceil(x0-0.5), 0))
+		to := int(math.Min(math.Ceil(x1-0.5), float64(len(coverage))))
+		for x := from; x < to; x++ {
+			coverage[x]++
+		}
+		return
+	}
+
+	x0, x1 = math.Max(x0, 0), math.Min(x1, float64(len(coverage)))
+	for x := int(x0); x < len(coverage) && float64(x) < x1; x++ {
+		coverage[x] += math.Min(x1, float64(x+1)) - math.Max(x0, float64(x))
+	}
+}
@@ -0,0 +1,139 @@
+package raster
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"github.com/stefanom/peano/geom"
+	"image/png"
+	"math"
+	"testing"
+)
+
+// lit returns the total brightness of the image, in pixels fully on.
+func lit(pix []uint8) float64 {
+	total := 0.0
+	for _, v := range pix {
+		total += float64(v) / 255
+	}
+	return total
+}
+
+func TestDraw(t *testing.T) {
+	frame := geom.NewLayer(1, 0.05, []geom.Path{{{0, 0}, {10, 0}, {10, 10}, {0, 10}}, {{3, 3}, {7, 3}, {7, 7}, {3, 7}}})
+	options := Options{PixelSize: 0.5, AntiAliasing: 1}
+	f := NewFrame([]*geom.Layer{frame}, &options)
+	if f.Width != 22 || f.Height != 22 {
+		t.Fatalf("Expected a %vx%v image, got %vx%v", 22, 22, f.Width, f.Height)
+	}
+
+	img := Draw(frame, f, &options)
+	if l := lit(img.Pix); l != 400-64 {
+		t.Errorf("Expected %v pixels on, got %v", 400-64, l)
+	}
+	for _, c := range []struct {
+		x, y  int
+		value uint8
+	}{{0, 0, 0}, {1, 1, 255}, {1, 20, 255}, {20, 1, 255}, {11, 11, 0}, {21, 21, 0}} {
+		if v := img.GrayAt(c.x, c.y).Y; v != c.value {
+			t.Errorf("Expected pixel %v,%v to be %v, got %v", c.x, c.y, c.value, v)
+		}
+	}
+}
+
+func TestDrawAntiAliasing(t *testing.T) {
+	// a square off the pixel grid, and a triangle
+	layer := geom.NewLayer(1, 0.05, []geom.Path{{{0.25, 0.25}, {2.25, 0.25}, {2.25, 2.25}, {0.25, 2.25}}, {{4, 0}, {6, 0}, {4, 2}}})
+	options := Options{PixelSize: 1, Width: 8, Height: 4, AntiAliasing: 8}
+	f := NewFrame([]*geom.Layer{layer}, &options)
+	img := Draw(layer, f, &options)
+
+	if l := lit(img.Pix); math.Abs(l-6) > 0.05 {
+		t.Errorf("Expected %v pixels on, got %v", 6, l)
+	}
+	gray := 0
+	for _, v := range img.Pix {
+		if v != 0 && v != 255 {
+			gray++
+		}
+	}
+	if gray == 0 {
+		t.Error("Expected some gray pixels")
+	}
+}
+
+func TestDrawMirror(t *testing.T) {
+	layer := geom.NewLayer(1, 0.05, []geom.Path{{{0, 0}, {4, 0}, {4, 4}, {0, 4}}, {{10, 0}, {11, 0}, {11, 1}, {10, 1}}})
+	options := Options{PixelSize: 1, AntiAliasing: 1}
+	f := NewFrame([]*geom.Layer{layer}, &options)
+	img := Draw(layer, f, &options)
+	options.Mirror = true
+	mirrored := Draw(layer, NewFrame([]*geom.Layer{layer}, &options), &options)
+
+	for y := 0; y < f.Height; y++ {
+		for x := 0; x < f.Width; x++ {
+			if img.GrayAt(x, y) != mirrored.GrayAt(f.Width-1-x, y) {
+				t.Fatalf("Pixel %v,%v is not mirrored", x, y)
+			}
+		}
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	var layers []*geom.Layer
+	for i := int32(1); i <= 3; i++ {
+		layers = append(layers, geom.NewLayer(i, float64(i)*0.05, []geom.Path{{{0, 0}, {2, 0}, {2, 2}, {0, 2}}}))
+	}
+
+	var b bytes.Buffer
+	options := Options{PixelSize: 0.1, Width: 40, Height: 30, AntiAliasing: 2}
+	exposure := Exposure{Time: 8, BottomTime: 40, BottomLayers: 2}
+	if err := WriteArchive(&b, layers, 0.05, &options, exposure); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.File) != 4 || archive.File[0].Name != ManifestName {
+		t.Fatalf("Unexpected archive content %v", archive.File)
+	}
+
+	r, _ := archive.File[0].Open()
+	var manifest Manifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Width != 40 || manifest.Height != 30 || manifest.LayerHeight != 0.05 || manifest.Exposure != exposure {
+		t.Errorf("Unexpected manifest %+v", manifest)
+	}
+	for i, l := range manifest.Layers {
+		expected := 8.0
+		if i < 2 {
+			expected = 40
+		}
+		if l.File != archive.File[i+1].Name || l.Index != int32(i+1) || l.Exposure != expected || math.Abs(l.Area-4) > 1e-6 {
+			t.Errorf("Unexpected layer %+v", l)
+		}
+	}
+
+	r, _ = archive.File[1].Open()
+	img, err := png.Decode(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 40 || size.Y != 30 {
+		t.Errorf("Unexpected image size %v", size)
+	}
+}
+
+func TestWriteArchiveInvalidOptions(t *testing.T) {
+	layers := []*geom.Layer{geom.NewLayer(1, 0.05, []geom.Path{{{0, 0}, {2, 0}, {2, 2}, {0, 2}}})}
+	for _, options := range []Options{{PixelSize: 0}, {PixelSize: -0.05}, {PixelSize: 0.05, Width: -1}, {PixelSize: 0.05, Height: -1}} {
+		var b bytes.Buffer
+		if err := WriteArchive(&b, layers, 0.05, &options, Exposure{}); err == nil {
+			t.Errorf("Expected an error for %+v", options)
+		}
+	}
+}
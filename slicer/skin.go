@@ -0,0 +1,128 @@
+// Package slicer turns the layers sliced out of a model into the regions
+// and toolpaths printing them.
+package slicer
+
+import (
+	"github.com/stefanom/peano/geom"
+	"github.com/stefanom/peano/infill"
+	"log/slog"
+)
+
+// Options control how layers are split into regions.
+type Options struct {
+	// Perimeters is the number of shells around every island.
+	Perimeters int
+	// LineWidth is the width of the extruded lines.
+	LineWidth float64
+	// TopLayers is how many solid layers there are below the top
+	// surfaces of the model.
+	TopLayers int
+	// BottomLayers is how many solid layers there are above the bottom
+	// surfaces of the model.
+	BottomLayers int
+	// Logger receives the warnings and the debug traces of the slicing, if
+	// set.
+	Logger *slog.Logger
+}
+
+// logger returns the logger of the options, or one discarding everything.
+func (o *Options) logger() *slog.Logger {
+	if o.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return o.Logger
+}
+
+// offsetOptions returns the options of the offsets of the regions.
+func (o *Options) offsetOptions() geom.OffsetOptions {
+	offset := geom.DefaultOffsetOptions
+	offset.Logger = o.Logger
+	return offset
+}
+
+// Regions are the parts of a layer printed differently.
+type Regions struct {
+	Layer *geom.Layer
+	// Perimeters are the paths followed by the center of the shells
+	// around the islands, the outermost first.
+	Perimeters [][]geom.Polygon
+	// Solid is the part of the inside of the shells close enough to a top
+	// or bottom surface to be filled solid.
+	Solid []geom.Polygon
+	// Sparse is the rest of the inside of the shells.
+	Sparse []geom.Polygon
+}
+
+// Split splits the layers, sorted by index, into regions. Layers missing
+// from the sequence are empty, and so are the layers beyond its ends.
+//
+// A part of a layer is filled solid unless the layers above and below it,
+// as many as there are top and bottom layers, are all there too. Solid
+// parts narrower than a line are left sparse. Islands too thin for a
+// single shell are filled instead, so that they aren't left out.
+func Split(layers []*geom.Layer, options Options) []*Regions {
+	byIndex := make(map[int32]*geom.Layer, len(layers))
+	for _, l := range layers {
+		byIndex[l.Index] = l
+	}
+
+	offset := options.offsetOptions()
+	regions := make([]*Regions, len(layers))
+	for i, l := range layers {
+		r := &Regions{Layer: l}
+		var inside []geom.Polygon
+		for j := range l.Islands {
+			island := l.Islands[j : j+1]
+			shells := geom.Perimeters(island, options.Perimeters, options.LineWidth, offset)
+			for k, shell := range shells {
+				if k == len(r.Perimeters) {
+					r.Perimeters = append(r.Perimeters, nil)
+				}
+				r.Perimeters[k] = append(r.Perimeters[k], shell...)
+			}
+
+			// Without shells, the fill goes half a line inside the island,
+			// or over all of it when it is thinner than a line.
+			var fill []geom.Polygon
+			if len(shells) > 0 {
+				fill = infill.Region(shells[len(shells)-1], options.LineWidth, infill.DefaultOverlap)
+			} else if fill = geom.Offset(island, -options.LineWidth/2, offset); len(fill) == 0 {
+				fill = island
+			}
+			inside = append(inside, fill...)
+		}
+
+		covered := coveredBy(byIndex, l, options.BottomLayers, options.TopLayers)
+		exposed := geom.Difference(geom.PolygonPaths(inside), geom.PolygonPaths(covered), geom.NonZero)
+		r.Solid = open(exposed, options.LineWidth/2, offset)
+		r.Sparse = geom.Difference(geom.PolygonPaths(inside), geom.PolygonPaths(r.Solid), geom.NonZero)
+		regions[i] = r
+	}
+	return regions
+}
+
+// coveredBy returns the part of the layer which the given number of layers
+// below and above it all cover.
+func coveredBy(layers map[int32]*geom.Layer, layer *geom.Layer, below, above int) []geom.Polygon {
+	covered := layer.Islands
+	for d := int32(-below); d <= int32(above) && len(covered) > 0; d++ {
+		if d == 0 {
+			continue
+		}
+		other, ok := layers[layer.Index+d]
+		if !ok {
+			return nil
+		}
+		covered = geom.Intersection(geom.PolygonPaths(covered), geom.PolygonPaths(other.Islands), geom.NonZero)
+	}
+	return covered
+}
+
+// open removes the parts of the polygons narrower than twice the distance,
+// shrinking and growing them back.
+func open(polygons []geom.Polygon, distance float64, offset geom.OffsetOptions) []geom.Polygon {
+	if distance <= 0 || len(polygons) == 0 {
+		return polygons
+	}
+	return geom.Offset(geom.Offset(polygons, -distance, offset), distance, offset)
+}
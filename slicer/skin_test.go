@@ -0,0 +1,93 @@
+package slicer
+
+import (
+	"github.com/stefanom/peano/geom"
+	"math"
+	"testing"
+)
+
+func TestSplitTopAndBottom(t *testing.T) {
+	// a 10 layer block
+	var layers []*geom.Layer
+	for i := int32(0); i < 10; i++ {
+		layers = append(layers, geom.NewLayer(i, float64(i)*0.2, []geom.Path{{{0, 0}, {20, 0}, {20, 20}, {0, 20}}}))
+	}
+
+	options := Options{Perimeters: 2, LineWidth: 0.5, TopLayers: 3, BottomLayers: 2}
+	regions := Split(layers, options)
+
+	// the inside of the shells, overlapping them a bit
+	inside := 20 - 2*(1.5*0.5+0.5*(1-0.15))
+	for i, r := range regions {
+		if len(r.Perimeters) != 2 {
+			t.Errorf("Layer %v: expected %v perimeters, got %v", i, 2, len(r.Perimeters))
+		}
+		solid, sparse := geom.Area(r.Solid), geom.Area(r.Sparse)
+		if math.Abs(solid+sparse-inside*inside) > 1e-2 {
+			t.Errorf("Layer %v: expected the regions to add up to %v, got %v and %v", i, inside*inside, solid, sparse)
+		}
+		if skin := i < 2 || i >= 7; skin != (sparse == 0) {
+			t.Errorf("Layer %v: expected skin to be %v, got solid %v and sparse %v", i, skin, solid, sparse)
+		}
+	}
+}
+
+func TestSplitOverhang(t *testing.T) {
+	// a small block under a big one, and a gap in the sequence above
+	var layers []*geom.Layer
+	for i := int32(0); i < 4; i++ {
+		layers = append(layers, geom.NewLayer(i, 0, []geom.Path{{{0, 0}, {10, 0}, {10, 10}, {0, 10}}}))
+	}
+	for i := int32(4); i < 8; i++ {
+		layers = append(layers, geom.NewLayer(i, 0, []geom.Path{{{0, 0}, {20, 0}, {20, 20}, {0, 20}}}))
+	}
+	layers = append(layers, geom.NewLayer(9, 0, []geom.Path{{{0, 0}, {20, 0}, {20, 20}, {0, 20}}}))
+
+	options := Options{Perimeters: 1, LineWidth: 0.5, TopLayers: 1, BottomLayers: 1}
+	regions := Split(layers, options)
+
+	// Layer 4 hangs over the small block: solid but where it stands on
+	// it.
+	if sparse := geom.Area(regions[4].Sparse); sparse < 80 || sparse > 100 {
+		t.Errorf("Expected the part over the block to be sparse, got %v", sparse)
+	}
+	if solid := geom.Area(regions[4].Solid); solid < 250 {
+		t.Errorf("Expected the overhang to be solid, got %v", solid)
+	}
+
+	// Layer 5 is covered above and below.
+	if solid := geom.Area(regions[5].Solid); solid != 0 {
+		t.Errorf("Expected no solid infill, got %v", solid)
+	}
+
+	// Layers 7 and 9, in regions 7 and 8, are next to the missing layer 8.
+	for _, i := range []int{7, 8} {
+		if sparse := geom.Area(regions[i].Sparse); sparse != 0 {
+			t.Errorf("Layer %v: expected no sparse infill, got %v", regions[i].Layer.Index, sparse)
+		}
+	}
+}
+
+func TestSplitThinIsland(t *testing.T) {
+	// a block with a wall too thin for a shell next to it
+	wall := geom.Path{{30, 0}, {30.3, 0}, {30.3, 10}, {30, 10}}
+	layers := []*geom.Layer{geom.NewLayer(0, 0.2, []geom.Path{{{0, 0}, {20, 0}, {20, 20}, {0, 20}}, wall})}
+
+	regions := Split(layers, Options{Perimeters: 2, LineWidth: 0.5, TopLayers: 1, BottomLayers: 1})
+
+	r := regions[0]
+	if len(r.Perimeters) != 2 || len(r.Perimeters[0]) != 1 {
+		t.Errorf("Expected shells around the block only, got %v", r.Perimeters)
+	}
+	inside := 20 - 2*(1.5*0.5+0.5*(1-0.15))
+	if filled := geom.Area(r.Solid) + geom.Area(r.Sparse); math.Abs(filled-inside*inside-3) > 1e-2 {
+		t.Errorf("Expected the inside of the block and the wall to be filled, got %v", filled)
+	}
+	filled := false
+	for _, p := range append(r.Solid, r.Sparse...) {
+		filled = filled || p.Contains(geom.Point{30.15, 5})
+	}
+	if !filled {
+		t.Error("Expected the wall to be filled")
+	}
+}
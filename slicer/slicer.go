@@ -0,0 +1,287 @@
+package slicer
+
+import (
+	"context"
+	"fmt"
+	"github.com/stefanom/peano/geom"
+	"github.com/stefanom/peano/infill"
+	"github.com/stefanom/peano/printer"
+	"github.com/stefanom/peano/stl"
+	"io"
+)
+
+// Config are the settings of the whole slicing, from the model to the
+// G-code.
+type Config struct {
+	Options
+	// LayerHeight is the height of the layers.
+	LayerHeight float64
+	// WeldTolerance is the distance within which the ends of the segments
+	// sliced out of facets are joined.
+	WeldTolerance float32
+	// Workers is the number of layers sliced in parallel.
+	Workers int
+	// Repair fixes the defects of the model before slicing it.
+	Repair bool
+	// Infill is the name of the pattern of the sparse infill.
+	Infill string
+	// InfillDensity is the fraction of the sparse regions filled.
+	InfillDensity float64
+	// InfillAngle is the direction of the infill, in degrees.
+	InfillAngle float64
+	// SkirtDistance is how far from the first layer a loop priming the
+	// nozzle goes around it, or 0 for no loop.
+	SkirtDistance float64
+	// KeepFacets keeps the facets in the model Process returns, even when
+	// they could be sliced as they are read.
+	KeepFacets bool
+}
+
+// DefaultConfig is a reasonable configuration for a 0.4mm nozzle.
+var DefaultConfig = Config{
+	Options: Options{
+		Perimeters:   2,
+		LineWidth:    0.4,
+		TopLayers:    3,
+		BottomLayers: 3,
+	},
+	LayerHeight:   0.2,
+	WeldTolerance: 1e-5,
+	Workers:       1,
+	Infill:        "rectilinear",
+	InfillDensity: 0.2,
+	InfillAngle:   45,
+	SkirtDistance: 5,
+}
+
+// Toolpaths are the paths printed on a layer.
+type Toolpaths struct {
+	Layer *geom.Layer
+	// Perimeters are the closed loops of the shells, repeating their first
+	// point, from the innermost shell out.
+	Perimeters []geom.Path
+	// Solid and Sparse are the lines of the infill.
+	Solid  []geom.Path
+	Sparse []geom.Path
+}
+
+// Result is what each stage of slicing a model produces.
+type Result struct {
+	// Model is the parsed model. Its facets are only kept when they have
+	// to be, or when the config asks for them.
+	Model *stl.Model
+	// Repair is the outcome of repairing the model, if it was.
+	Repair    *geom.RepairResult
+	Layers    []*geom.Layer
+	Regions   []*Regions
+	Toolpaths []*Toolpaths
+}
+
+// Slice reads an STL model and writes the G-code printing it with the
+// printer. The model is centered on the printer and laid on its bed.
+func Slice(ctx context.Context, r io.Reader, p *printer.Printer, config *Config) error {
+	result, err := Process(ctx, stl.NewParser(r), config)
+	if err != nil {
+		return err
+	}
+	WriteGCode(p, result.Toolpaths, config)
+	return nil
+}
+
+// Process reads an STL model with the parser and takes it through all
+// the stages of slicing, up to the toolpaths. The facets are sliced as
+// they are read, unless they have to be repaired, sliced in parallel or
+// kept. Canceling the context stops the processing, which then returns
+// the error of the context.
+func Process(ctx context.Context, parser *stl.Parser, config *Config) (*Result, error) {
+	result := new(Result)
+	keep := config.KeepFacets || config.Repair || config.Workers > 1
+	s := geom.NewSlicer(config.LayerHeight)
+	s.Logger = config.Logger
+	var facets []geom.Facet
+	model, err := parser.ParseFunc(func(facet geom.Facet) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if keep {
+			facets = append(facets, facet)
+		} else {
+			s.AddFacet(facet)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	model.Facets = facets
+	result.Model = model
+
+	if config.Repair {
+		options := geom.DefaultRepairOptions
+		options.Tolerance = config.WeldTolerance
+		options.Logger = config.Logger
+		result.Repair = model.Repair(options)
+	}
+
+	if keep {
+		result.Layers, err = Layers(ctx, model.Facets, config)
+	} else {
+		result.Layers, err = stitchAll(ctx, s.Layers(), config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.Regions = Split(result.Layers, config.Options)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if result.Toolpaths, err = Fill(result.Regions, config); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Layers slices the facets into layers, from the bottom up. Canceling the
+// context stops the slicing, which then returns the error of the context.
+func Layers(ctx context.Context, facets []geom.Facet, config *Config) ([]*geom.Layer, error) {
+	if config.Workers > 1 {
+		var layers []*geom.Layer
+		for l := range geom.SliceParallel(ctx, facets, config.LayerHeight, config.Workers) {
+			layers = append(layers, Stitch(l, config))
+		}
+		// the channel is closed early when the context is canceled
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return layers, nil
+	}
+
+	s := geom.NewSlicer(config.LayerHeight)
+	s.Logger = config.Logger
+	for _, facet := range facets {
+		s.AddFacet(facet)
+	}
+	return stitchAll(ctx, s.Layers(), config)
+}
+
+// stitchAll stitches the sliced layers, until the context is canceled.
+func stitchAll(ctx context.Context, sliced []geom.LayerSegments, config *Config) ([]*geom.Layer, error) {
+	layers := make([]*geom.Layer, 0, len(sliced))
+	for _, l := range sliced {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		layers = append(layers, Stitch(l, config))
+	}
+	return layers, nil
+}
+
+// Stitch joins the segments sliced out of a layer into its islands,
+// leaving out the paths which don't close. Those are reported as warnings
+// of the "layers" subsystem, and traced in debug.
+func Stitch(l geom.LayerSegments, config *Config) *geom.Layer {
+	contours := geom.Stitch(l.Segments, geom.StitchOptions{Tolerance: config.WeldTolerance, Logger: config.Logger})
+	layer := geom.NewLayer(l.Index, l.Z, contours.Closed)
+	if len(contours.Open) > 0 {
+		log := config.Options.logger().With("subsystem", "layers", "layer", layer.Index)
+		log.Warn("open paths", "count", len(contours.Open))
+		for _, path := range contours.Open {
+			log.Debug("open path", "path", path)
+		}
+	}
+	return layer
+}
+
+// Fill lays the toolpaths over the regions of the layers: the perimeters,
+// the solid regions with lines crossing from one layer to the next, and
+// the sparse regions with the infill pattern.
+func Fill(regions []*Regions, config *Config) ([]*Toolpaths, error) {
+	sparse, err := infill.New(config.Infill, infill.Options{
+		Density:   config.InfillDensity,
+		LineWidth: config.LineWidth,
+		Angle:     config.InfillAngle,
+	})
+	if err != nil {
+		return nil, err
+	}
+	solid := &infill.Rectilinear{Density: 1, LineWidth: config.LineWidth, Angle: config.InfillAngle}
+
+	toolpaths := make([]*Toolpaths, len(regions))
+	for i, r := range regions {
+		t := &Toolpaths{Layer: r.Layer}
+		for j := len(r.Perimeters) - 1; j >= 0; j-- {
+			for _, path := range geom.PolygonPaths(r.Perimeters[j]) {
+				t.Perimeters = append(t.Perimeters, append(path[:len(path):len(path)], path[0]))
+			}
+		}
+		t.Solid = solid.Fill(r.Solid, r.Layer.Index, r.Layer.Z)
+		t.Sparse = sparse.Fill(r.Sparse, r.Layer.Index, r.Layer.Z)
+		toolpaths[i] = t
+	}
+	return toolpaths, nil
+}
+
+// WriteGCode prints the toolpaths of the layers with the printer. The
+// layers are moved so that the middle of the model is at the center of
+// the printer, and the first one at a layer height above the bed. The
+// extrusion is computed for the layer height of the config the layers
+// were sliced with, whatever the one of the printer, which is left as is.
+func WriteGCode(p *printer.Printer, toolpaths []*Toolpaths, config *Config) {
+	sliced := *p
+	sliced.LayerHeight = config.LayerHeight
+	p = &sliced
+
+	p.Preamble()
+	if len(toolpaths) == 0 {
+		p.Postamble()
+		return
+	}
+
+	bounds := toolpaths[0].Layer.Bounds()
+	for _, t := range toolpaths {
+		bounds = bounds.Union(t.Layer.Bounds())
+	}
+	dx := -float64(bounds.Min[0]+bounds.Max[0]) / 2
+	dy := -float64(bounds.Min[1]+bounds.Max[1]) / 2
+	bottom := toolpaths[0].Layer.Z - config.LayerHeight
+	spread := p.Spread(config.LineWidth)
+
+	print := func(paths []geom.Path) {
+		for _, path := range paths {
+			if len(path) < 2 {
+				continue
+			}
+			p.MoveAndRetract(float64(path[0][0])+dx, float64(path[0][1])+dy)
+			for _, point := range path[1:] {
+				p.Print(float64(point[0])+dx, float64(point[1])+dy, spread)
+			}
+		}
+	}
+
+	for i, t := range toolpaths {
+		p.Comment("layer: %d", t.Layer.Index)
+		p.LayerChange(i, t.Layer.Z-bottom)
+
+		if i == 0 && config.SkirtDistance > 0 {
+			p.Comment("skirt")
+			skirt := geom.Offset(t.Layer.Islands, config.SkirtDistance, geom.OffsetOptions{Join: geom.RoundJoin, ArcTolerance: 0.05, Logger: config.Logger})
+			for _, polygon := range skirt {
+				outer := polygon.Outer
+				print([]geom.Path{append(outer[:len(outer):len(outer)], outer[0])})
+			}
+		}
+
+		print(t.Perimeters)
+		print(t.Solid)
+		print(t.Sparse)
+	}
+
+	p.Postamble()
+}
+
+// String returns a summary of the toolpaths.
+func (t *Toolpaths) String() string {
+	return fmt.Sprintf("layer %d at %.3f: %d perimeter loops, %d solid lines, %d sparse lines",
+		t.Layer.Index, t.Layer.Z, len(t.Perimeters), len(t.Solid), len(t.Sparse))
+}
@@ -0,0 +1,169 @@
+package slicer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stefanom/peano/geom"
+	"github.com/stefanom/peano/printer"
+	"github.com/stefanom/peano/stl"
+	"strings"
+	"testing"
+)
+
+// box returns the facets of a box going from min to max, facing out.
+func box(min, max geom.Vector) []geom.Facet {
+	corner := func(c [3]int) geom.Vector {
+		v := min
+		for i := range c {
+			if c[i] == 1 {
+				v[i] = max[i]
+			}
+		}
+		return v
+	}
+
+	quads := [][4][3]int{
+		{{0, 0, 0}, {0, 1, 0}, {1, 1, 0}, {1, 0, 0}},
+		{{0, 0, 1}, {1, 0, 1}, {1, 1, 1}, {0, 1, 1}},
+		{{0, 0, 0}, {1, 0, 0}, {1, 0, 1}, {0, 0, 1}},
+		{{0, 1, 0}, {0, 1, 1}, {1, 1, 1}, {1, 1, 0}},
+		{{0, 0, 0}, {0, 0, 1}, {0, 1, 1}, {0, 1, 0}},
+		{{1, 0, 0}, {1, 1, 0}, {1, 1, 1}, {1, 0, 1}},
+	}
+	var facets []geom.Facet
+	for _, q := range quads {
+		a, b, c, d := corner(q[0]), corner(q[1]), corner(q[2]), corner(q[3])
+		facets = append(facets,
+			geom.Facet{Vertex1: a, Vertex2: b, Vertex3: c},
+			geom.Facet{Vertex1: a, Vertex2: c, Vertex3: d})
+	}
+	for i := range facets {
+		facets[i].Normal = facets[i].ComputeNormal()
+	}
+	return facets
+}
+
+func TestFill(t *testing.T) {
+	config := DefaultConfig
+	layers, err := Layers(context.Background(), box(geom.Vector{0, 0, 0}, geom.Vector{10, 10, 2}), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 10 {
+		t.Fatalf("Expected %v layers, got %v", 10, len(layers))
+	}
+
+	toolpaths, err := Fill(Split(layers, config.Options), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tp := range toolpaths {
+		if len(tp.Perimeters) != 2 {
+			t.Errorf("Layer %v: expected %v perimeter loops, got %v", i, 2, len(tp.Perimeters))
+		}
+		solid := i < 3 || i >= 7
+		if solid != (len(tp.Solid) > 0) || solid == (len(tp.Sparse) > 0) {
+			t.Errorf("Layer %v: expected solid infill to be %v, got %v", i, solid, tp)
+		}
+	}
+
+	config.Infill = "spiral"
+	if _, err := Fill(Split(layers, config.Options), &config); err == nil {
+		t.Error("Expected an error for an unknown pattern")
+	}
+}
+
+func TestSlice(t *testing.T) {
+	var model bytes.Buffer
+	m := &stl.Model{Facets: box(geom.Vector{20, 30, 5}, geom.Vector{30, 40, 6})}
+	if err := stl.NewSerializer(&model).SerializeAsBinary(m); err != nil {
+		t.Fatal(err)
+	}
+
+	var gcode bytes.Buffer
+	p := &printer.Printer{
+		Output:           &gcode,
+		Temperature:      210,
+		TravelSpeed:      150,
+		PrintSpeed:       20,
+		FlowCorrection:   1,
+		CenterX:          100,
+		CenterY:          100,
+		LayerHeight:      0.2,
+		FilamentDiameter: 1.75,
+		RetractionSpeed:  20,
+		RetractionLength: 2,
+	}
+	config := DefaultConfig
+	if err := Slice(context.Background(), bytes.NewReader(model.Bytes()), p, &config); err != nil {
+		t.Fatal(err)
+	}
+
+	out := gcode.String()
+	if !strings.HasPrefix(out, "G28") || !strings.Contains(out, "M84") {
+		t.Error("Expected the preamble and the postamble")
+	}
+	if n := strings.Count(out, "; ------- layer:"); n != 5 {
+		t.Errorf("Expected %v layers, got %v", 5, n)
+	}
+	if n := strings.Count(out, "; ------- skirt"); n != 1 {
+		t.Errorf("Expected %v skirt, got %v", 1, n)
+	}
+	if !strings.Contains(out, "G0 Z0.200 ") || !strings.Contains(out, "G0 Z1.000 ") {
+		t.Error("Expected the layers to start from the bed")
+	}
+
+	// everything is printed around the center of the printer
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.HasSuffix(line, "; print") {
+			continue
+		}
+		var x, y float64
+		if _, err := fmt.Sscanf(line, "G0 X%f Y%f", &x, &y); err != nil {
+			t.Fatal(err)
+		}
+		if x < 100-11 || x > 100+11 || y < 100-11 || y > 100+11 {
+			t.Errorf("Printing out of the model: %v", line)
+		}
+	}
+
+	// the extrusion is for the layers as sliced, whatever the printer says
+	var thick bytes.Buffer
+	q := *p
+	q.Output, q.LayerHeight = &thick, 0.3
+	if err := Slice(context.Background(), bytes.NewReader(model.Bytes()), &q, &config); err != nil {
+		t.Fatal(err)
+	}
+	if thick.String() != out {
+		t.Error("Expected the same G-code for a printer with another layer height")
+	}
+	if q.LayerHeight != 0.3 {
+		t.Errorf("Expected the layer height of the printer to be left as %v, got %v", 0.3, q.LayerHeight)
+	}
+
+	// a broken model is reported
+	if err := Slice(context.Background(), strings.NewReader("solid broken\nfacet normal 0 0"), p, &config); err == nil {
+		t.Error("Expected an error for a broken model")
+	}
+}
+
+func TestProcessCanceled(t *testing.T) {
+	var model bytes.Buffer
+	m := &stl.Model{Facets: box(geom.Vector{0, 0, 0}, geom.Vector{10, 10, 10})}
+	if err := stl.NewSerializer(&model).SerializeAsBinary(m); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, workers := range []int{1, 4} {
+		config := DefaultConfig
+		config.Workers = workers
+		parser := stl.NewParser(bytes.NewReader(model.Bytes()))
+		if _, err := Process(ctx, parser, &config); !errors.Is(err, context.Canceled) {
+			t.Errorf("%v workers: expected the processing to be canceled, got %v", workers, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package stl
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrEmpty is returned when there is no data to parse at all.
+var ErrEmpty = errors.New("stl: empty file")
+
+// Location describes where in a file a problem was found. Line and column
+// are only known for ASCII files and are zero otherwise.
+type Location struct {
+	Pos
+	Facet int // index of the facet being read, or -1 if outside of a facet
+}
+
+// Where returns the location itself, so that every error embedding a
+// Location implements Error.
+func (l Location) Where() Location { return l }
+
+func (l Location) String() string {
+	var where string
+	if l.Line > 0 {
+		where = l.Pos.String()
+	} else {
+		where = fmt.Sprintf("byte %d", l.Offset)
+	}
+	if l.Facet >= 0 {
+		where += fmt.Sprintf(" (facet %d)", l.Facet)
+	}
+	return where
+}
+
+// Error is implemented by all the errors describing a malformed STL file.
+// Errors from the underlying reader are returned unchanged instead.
+//
+// The concrete types tell what kind of problem was found: a SyntaxError
+// means the file is not valid STL, while a TruncatedError or a
+// CountMismatchError mean that the facets read before the problem are
+// valid and can be used to attempt a repair.
+type Error interface {
+	error
+	Where() Location
+}
+
+// SyntaxError is returned when an ASCII file contains something other than
+// what the STL grammar allows.
+type SyntaxError struct {
+	Location
+	Found    string // the literal found
+	Expected string // a description of what was expected
+	Err      error  // the underlying error, if any
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("stl: %v: invalid %s %q: %v", e.Location, e.Expected, e.Found, e.Err)
+	}
+	return fmt.Sprintf("stl: %v: found %q, expected %s", e.Location, e.Found, e.Expected)
+}
+
+func (e *SyntaxError) Unwrap() error { return e.Err }
+
+// TruncatedError is returned when the data ends before the end of the
+// structure being read.
+type TruncatedError struct {
+	Location
+	What string // the structure being read
+	Want int64  // number of bytes needed, or 0 if unknown
+}
+
+func (e *TruncatedError) Error() string {
+	if e.Want > 0 {
+		return fmt.Sprintf("stl: truncated file at %v: %s needs %d bytes", e.Location, e.What, e.Want)
+	}
+	return fmt.Sprintf("stl: truncated file at %v: expected %s", e.Location, e.What)
+}
+
+// CountMismatchError is returned when the number of facets declared in a
+// binary file does not match the number of facet records it contains.
+type CountMismatchError struct {
+	Location
+	Declared int64 // number of facets declared in the file
+	Found    int64 // number of complete facet records found
+	Extra    int64 // number of bytes after the last complete facet record
+}
+
+func (e *CountMismatchError) Error() string {
+	if e.Extra > 0 {
+		return fmt.Sprintf("stl: %v: file declares %d facets but contains %d facets and %d extra bytes", e.Location, e.Declared, e.Found, e.Extra)
+	}
+	return fmt.Sprintf("stl: %v: file declares %d facets but contains %d", e.Location, e.Declared, e.Found)
+}
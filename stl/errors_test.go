@@ -0,0 +1,85 @@
+package stl
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSyntaxError(t *testing.T) {
+	data := "solid cube\n" +
+		"  facet normal 0 0 1\n" +
+		"    outer loop\n" +
+		"      vertex 0 0 0\n" +
+		"      vertex 1 0 0\n" +
+		"      vertex 0 1 0\n" +
+		"    endloop\n" +
+		"  endfacet\n" +
+		"  facet normal 0 0 1\n" +
+		"    outer loop\n" +
+		"      vertex 0 0 0\n" +
+		"      vertes 1 0 0\n"
+
+	model, err := NewParser(strings.NewReader(data)).Parse()
+	e, ok := err.(*SyntaxError)
+	if !ok {
+		t.Fatalf("Expected a SyntaxError, got %v", err)
+	}
+	if e.Line != 12 || e.Column != 7 || e.Offset != 188 || e.Facet != 1 {
+		t.Errorf("Unexpected location %+v", e.Location)
+	}
+	if e.Found != "vertes" || e.Expected != "'vertex'" {
+		t.Errorf("Unexpected error %v", e)
+	}
+	if len(model.Facets) != 1 {
+		t.Errorf("Expected the %v facet before the error, got %v", 1, len(model.Facets))
+	}
+}
+
+func TestInvalidNumber(t *testing.T) {
+	data := "solid x\n facet normal 0 0 1e99\n"
+
+	_, err := NewParser(strings.NewReader(data)).Parse()
+	e, ok := err.(*SyntaxError)
+	if !ok {
+		t.Fatalf("Expected a SyntaxError, got %v", err)
+	}
+	if !errors.Is(err, strconv.ErrRange) {
+		t.Errorf("Expected the error to wrap strconv.ErrRange, got %v", e.Err)
+	}
+	if e.Line != 2 || e.Column != 19 || e.Facet != 0 {
+		t.Errorf("Unexpected location %+v", e.Location)
+	}
+}
+
+func TestTruncatedAscii(t *testing.T) {
+	data := "solid cube\n  facet normal 0 0 1\n    outer loop\n      vertex 0 0\n"
+
+	_, err := NewParser(strings.NewReader(data)).Parse()
+	e, ok := err.(*TruncatedError)
+	if !ok {
+		t.Fatalf("Expected a TruncatedError, got %v", err)
+	}
+	if e.Line != 5 || e.Column != 1 || e.Offset != int64(len(data)) || e.Facet != 0 {
+		t.Errorf("Unexpected location %+v", e.Location)
+	}
+}
+
+func TestCountMismatchLocation(t *testing.T) {
+	data := solidHeader(t)
+
+	model, err := NewParser(&unsized{bytes.NewReader(data[:len(data)-60])}).Parse()
+	var e Error
+	if !errors.As(err, &e) {
+		t.Fatalf("Expected an Error, got %v", err)
+	}
+	loc := e.Where()
+	if loc.Offset != int64(len(data)-60) || loc.Facet != 10 || loc.Line != 0 {
+		t.Errorf("Unexpected location %+v", loc)
+	}
+	if len(model.Facets) != 10 {
+		t.Errorf("Expected the %v facets before the error, got %v", 10, len(model.Facets))
+	}
+}
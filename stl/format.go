@@ -0,0 +1,126 @@
+package stl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Format represents the encoding of an STL file.
+type Format int
+
+const (
+	UnknownFormat Format = iota
+	AsciiFormat
+	BinaryFormat
+)
+
+func (f Format) String() string {
+	switch f {
+	case AsciiFormat:
+		return "ascii"
+	case BinaryFormat:
+		return "binary"
+	}
+	return "unknown"
+}
+
+// binaryPrefixSize is the size of the binary header plus the facet count.
+const binaryPrefixSize = headerSize + 4
+
+// sniffSize is how many bytes we look at to tell text from binary data.
+const sniffSize = 512
+
+// detectFormat figures out whether the data is an ASCII or a binary STL.
+//
+// Many binary files exported by CAD tools have headers starting with
+// "solid", so the header alone is not enough: when the size of the input is
+// known and matches the facet count of the binary layout the data is
+// binary, and otherwise we check whether the data looks like text. Binary
+// files with missing or trailing data are then still parsed as binary, and
+// reported as such.
+func (p *Parser) detectFormat() (Format, error) {
+	size, sized := inputSize(p.src)
+
+	prefix, err := p.r.Peek(binaryPrefixSize)
+	if err != nil && err != io.EOF {
+		return UnknownFormat, err
+	}
+	if len(prefix) == 0 {
+		return UnknownFormat, ErrEmpty
+	}
+
+	ascii := hasSolidKeyword(prefix)
+	if len(prefix) < binaryPrefixSize {
+		if ascii {
+			return AsciiFormat, nil
+		}
+		loc := Location{Pos: Pos{Offset: int64(len(prefix))}, Facet: -1}
+		return UnknownFormat, &TruncatedError{Location: loc, What: "binary header", Want: binaryPrefixSize}
+	}
+
+	if sized {
+		count := int64(binary.LittleEndian.Uint32(prefix[headerSize:]))
+		if size == binaryPrefixSize+facetSize*count {
+			return BinaryFormat, nil
+		}
+	}
+
+	if !ascii {
+		return BinaryFormat, nil
+	}
+
+	sniff, err := p.r.Peek(sniffSize)
+	if err != nil && err != io.EOF {
+		return UnknownFormat, err
+	}
+	if isText(sniff) {
+		return AsciiFormat, nil
+	}
+	return BinaryFormat, nil
+}
+
+// hasSolidKeyword returns true if the data starts with the "solid" keyword.
+func hasSolidKeyword(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	if len(data) < 5 || !bytes.EqualFold(data[:5], []byte("solid")) {
+		return false
+	}
+	return len(data) == 5 || bytes.IndexByte([]byte(" \t\r\n"), data[5]) >= 0
+}
+
+// isText returns true if the data only contains printable ASCII characters
+// and whitespace.
+func isText(data []byte) bool {
+	for _, b := range data {
+		if (b < ' ' || b > '~') && b != '\t' && b != '\r' && b != '\n' {
+			return false
+		}
+	}
+	return true
+}
+
+// inputSize returns the number of bytes left to read in r, if that can be
+// determined without consuming it.
+func inputSize(r io.Reader) (int64, bool) {
+	switch v := r.(type) {
+	case interface {
+		Len() int
+	}:
+		return int64(v.Len()), true
+	case io.Seeker:
+		current, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		end, err := v.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+		if _, err := v.Seek(current, io.SeekStart); err != nil {
+			return 0, false
+		}
+		return end - current, true
+	}
+	return 0, false
+}
@@ -0,0 +1,106 @@
+package stl
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// solidHeader returns the binary cube with a header starting with "solid",
+// like the ones written by many CAD tools.
+func solidHeader(t *testing.T) []byte {
+	data, err := ioutil.ReadFile("test_data/cube.binary.stl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(data, "solid cube exported by some CAD tool")
+	return data
+}
+
+// unsized hides the size of the underlying reader.
+type unsized struct {
+	r io.Reader
+}
+
+func (u *unsized) Read(p []byte) (int, error) { return u.r.Read(p) }
+
+func TestBinaryWithSolidHeader(t *testing.T) {
+	data := solidHeader(t)
+
+	for _, r := range []io.Reader{bytes.NewReader(data), &unsized{bytes.NewReader(data)}} {
+		model, err := NewParser(r).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if model.Format != BinaryFormat {
+			t.Errorf("Expected %v format, got %v", BinaryFormat, model.Format)
+		}
+		if len(model.Facets) != 12 {
+			t.Errorf("Expected %v facets, got %v", 12, len(model.Facets))
+		}
+	}
+}
+
+func TestAsciiDetection(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/cube.ascii.stl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []io.Reader{bytes.NewReader(data), &unsized{bytes.NewReader(data)}} {
+		model, err := NewParser(r).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if model.Format != AsciiFormat {
+			t.Errorf("Expected %v format, got %v", AsciiFormat, model.Format)
+		}
+	}
+}
+
+func TestShortFiles(t *testing.T) {
+	if _, err := NewParser(bytes.NewReader(nil)).Parse(); err != ErrEmpty {
+		t.Errorf("Expected ErrEmpty, got %v", err)
+	}
+
+	_, err := NewParser(bytes.NewReader(make([]byte, 40))).Parse()
+	if _, ok := err.(*TruncatedError); !ok {
+		t.Errorf("Expected a TruncatedError, got %v", err)
+	}
+
+	model, err := NewParser(bytes.NewReader([]byte("solid empty\nendsolid empty\n"))).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(model.Facets) != 0 {
+		t.Errorf("Expected no facets, got %v", len(model.Facets))
+	}
+}
+
+func TestCountMismatch(t *testing.T) {
+	data := solidHeader(t)
+
+	_, err := NewParser(&unsized{bytes.NewReader(data[:len(data)-10])}).Parse()
+	if e, ok := err.(*CountMismatchError); !ok {
+		t.Errorf("Expected a CountMismatchError, got %v", err)
+	} else if e.Declared != 12 || e.Found != 11 || e.Extra != 40 {
+		t.Errorf("Unexpected mismatch: %+v", e)
+	}
+}
+
+func TestSizedCountMismatch(t *testing.T) {
+	data := solidHeader(t)
+
+	_, err := NewParser(bytes.NewReader(data[:len(data)-10])).Parse()
+	if e, ok := err.(*CountMismatchError); !ok {
+		t.Errorf("Expected a CountMismatchError, got %v", err)
+	} else if e.Declared != 12 || e.Found != 11 || e.Extra != 40 {
+		t.Errorf("Unexpected mismatch: %+v", e)
+	}
+
+	model, err := NewParser(bytes.NewReader(append(data, make([]byte, 20)...))).Parse()
+	if model == nil || model.Format != BinaryFormat {
+		t.Errorf("Expected a binary model, got %+v with %v", model, err)
+	}
+}
@@ -1,168 +1,268 @@
 package stl
 
 import (
-	"bytes"
+	"bufio"
 	"encoding/binary"
-	"fmt"
 	"github.com/stefanom/peano/geom"
 	"io"
 	"io/ioutil"
+	"math"
 	"strconv"
 )
 
+// headerSize is the size in bytes of the binary STL header.
+const headerSize = 80
+
+// facetSize is the size in bytes of a binary STL facet record: twelve
+// little-endian float32 values followed by a 16 bit attribute word.
+const facetSize = 50
+
 type Model struct {
+	Name   string
 	Header [80]byte
 	Length int32
 	Facets []geom.Facet
+	Format Format
+}
+
+// Mesh returns the indexed mesh of the model, welding vertices closer than
+// tolerance to each other.
+func (m *Model) Mesh(tolerance float32) *geom.Mesh {
+	return geom.NewMesh(m.Facets, tolerance)
 }
 
+// Repair fixes the defects of the model facets, see geom.Repair.
+func (m *Model) Repair(options geom.RepairOptions) *geom.RepairResult {
+	facets, result := geom.Repair(m.Facets, options)
+	m.Facets = facets
+	m.Length = int32(len(facets))
+	return result
+}
+
+// FacetFunc is called by ParseFunc for every facet as soon as it is read.
+// Returning an error stops the parsing and makes ParseFunc return it.
+type FacetFunc func(facet geom.Facet) error
+
 type Parser struct {
-	r   io.Reader
-	s   *Scanner
-	buf struct {
+	// RecomputeNormals makes the parser replace the normal of every facet
+	// with the one computed from its vertex order, instead of trusting the
+	// one in the file.
+	RecomputeNormals bool
+
+	src   io.Reader
+	r     *bufio.Reader
+	s     *Scanner
+	pos   Pos // position of the current token
+	facet int // index of the facet being read, or -1
+	buf   struct {
 		tok Token  // last read token
 		lit string // last read literal
+		pos Pos    // last read position
 		n   int    // buffer size (max=1)
 	}
 }
 
 // NewParser returns a new instance of Parser.
 func NewParser(r io.Reader) *Parser {
-	return &Parser{r: r}
+	return &Parser{src: r, r: bufio.NewReader(r)}
 }
 
-// Parse the STL file into a Model
+// Parse the STL file into a Model. When the file is malformed, the Model
+// holds the facets read before the problem.
 func (p *Parser) Parse() (*Model, error) {
-	data, err := ioutil.ReadAll(p.r)
-	if err != nil {
-		panic(err)
+	facets := make([]geom.Facet, 0)
+	m, err := p.ParseFunc(func(facet geom.Facet) error {
+		facets = append(facets, facet)
+		return nil
+	})
+	if m != nil {
+		m.Facets = facets
 	}
+	return m, err
+}
 
+// ParseFunc reads the STL file incrementally and calls fn for every facet
+// instead of collecting them, so that arbitrarily large models can be
+// processed without holding them in memory. The returned Model carries the
+// header and the facet count but no facets.
+//
+// Problems with the content of the file are reported as an Error, which
+// describes where the problem is. The Model is returned along with it, and
+// all the facets before the one in error have been passed to fn.
+func (p *Parser) ParseFunc(fn FacetFunc) (*Model, error) {
 	m := new(Model)
+	p.facet = -1
 
-	err = binary.Read(bytes.NewBuffer(data[0:80]), binary.LittleEndian, &m.Header)
+	if p.RecomputeNormals {
+		next := fn
+		fn = func(facet geom.Facet) error {
+			facet.Normal = facet.ComputeNormal()
+			return next(facet)
+		}
+	}
+
+	format, err := p.detectFormat()
 	if err != nil {
-		return m, err
+		return nil, err
 	}
+	m.Format = format
 
-	start := string(m.Header[:6])
-	if start == "solid " {
-		p.s = NewScanner(bytes.NewReader(data))
-		m.Facets = make([]geom.Facet, 0)
+	if format == AsciiFormat {
+		return m, p.parseAscii(m, fn)
+	}
+	return m, p.parseBinary(m, fn)
+}
 
-		// First token should be the "solid" keyword.
-		if tok, lit := p.scanIgnoreWhitespace(); tok != SOLID {
-			return nil, fmt.Errorf("found %q, expected 'solid'", lit)
-		}
+// parseAscii reads the facets of an ASCII file one at a time.
+func (p *Parser) parseAscii(m *Model, fn FacetFunc) error {
+	header, _ := p.r.Peek(headerSize)
+	copy(m.Header[:], header)
 
-		// Next is the name of the solid, which we ignore.
-		p.scanIgnoreWhitespace()
+	p.s = NewScanner(p.r)
 
-		// Then we loop over the facets.
-		for {
-			// Read a field.
-			tok, lit := p.scanIgnoreWhitespace()
-			if tok != FACET && tok != ENDSOLID {
-				return nil, fmt.Errorf("found %q, expected 'facet' or 'endsolid'", lit)
-			}
+	// First token should be the "solid" keyword.
+	if tok, lit := p.scanIgnoreWhitespace(); tok != SOLID {
+		return p.unexpected(tok, lit, "'solid'")
+	}
 
-			if tok == ENDSOLID {
-				p.unscan()
-				break
-			}
+	// Next is the name of the solid, which runs to the end of the line.
+	m.Name = p.s.ScanLine()
 
-			facet := new(geom.Facet)
+	// Then we loop over the facets.
+	for {
+		// Read a field.
+		tok, lit := p.scanIgnoreWhitespace()
+		if tok != FACET && tok != ENDSOLID {
+			return p.unexpected(tok, lit, "'facet' or 'endsolid'")
+		}
 
-			// Now we read the facet normal.
-			if tok, lit := p.scanIgnoreWhitespace(); tok != NORMAL {
-				return nil, fmt.Errorf("found %q, expected 'normal'", lit)
-			}
+		if tok == ENDSOLID {
+			p.unscan()
+			break
+		}
 
-			normal := new(geom.Vector)
-			for j := 0; j < 3; j++ {
-				tok, lit := p.scanIgnoreWhitespace()
-				if tok != NUMBER {
-					return nil, fmt.Errorf("found %q, expected number", lit)
-				}
-				coordinate, err := strconv.ParseFloat(lit, 32)
-				if err != nil {
-					return nil, err
-				}
-				normal[j] = float32(coordinate)
-			}
-			facet.Normal = *normal
+		p.facet++
+		facet := new(geom.Facet)
 
-			// Now we read the facet vertices.
-			if tok, lit := p.scanIgnoreWhitespace(); tok != OUTER {
-				return nil, fmt.Errorf("found %q, expected 'outer'", lit)
-			}
-			if tok, lit := p.scanIgnoreWhitespace(); tok != LOOP {
-				return nil, fmt.Errorf("found %q, expected 'loop'", lit)
-			}
+		// Now we read the facet normal.
+		if tok, lit := p.scanIgnoreWhitespace(); tok != NORMAL {
+			return p.unexpected(tok, lit, "'normal'")
+		}
+		if err := p.scanVector(&facet.Normal); err != nil {
+			return err
+		}
 
-			vectors := make([]geom.Vector, 3)
-			for i := 0; i < 3; i++ {
-				if tok, lit := p.scanIgnoreWhitespace(); tok != VERTEX {
-					return nil, fmt.Errorf("found %q, expected 'vertex'", lit)
-				}
-				for j := 0; j < 3; j++ {
-					tok, lit := p.scanIgnoreWhitespace()
-					if tok != NUMBER {
-						return nil, fmt.Errorf("found %q, expected number", lit)
-					}
-					coordinate, err := strconv.ParseFloat(lit, 32)
-					if err != nil {
-						return nil, err
-					}
-					vectors[i][j] = float32(coordinate)
-				}
-			}
-			facet.Vertex1 = vectors[0]
-			facet.Vertex2 = vectors[1]
-			facet.Vertex3 = vectors[2]
+		// Now we read the facet vertices.
+		if tok, lit := p.scanIgnoreWhitespace(); tok != OUTER {
+			return p.unexpected(tok, lit, "'outer'")
+		}
+		if tok, lit := p.scanIgnoreWhitespace(); tok != LOOP {
+			return p.unexpected(tok, lit, "'loop'")
+		}
 
-			if tok, lit := p.scanIgnoreWhitespace(); tok != ENDLOOP {
-				return nil, fmt.Errorf("found %q, expected 'endloop'", lit)
+		for _, vertex := range []*geom.Vector{&facet.Vertex1, &facet.Vertex2, &facet.Vertex3} {
+			if tok, lit := p.scanIgnoreWhitespace(); tok != VERTEX {
+				return p.unexpected(tok, lit, "'vertex'")
 			}
-
-			if tok, lit := p.scanIgnoreWhitespace(); tok != ENDFACET {
-				return nil, fmt.Errorf("found %q, expected 'endfacet'", lit)
+			if err := p.scanVector(vertex); err != nil {
+				return err
 			}
+		}
 
-			m.Facets = append(m.Facets, *facet)
+		if tok, lit := p.scanIgnoreWhitespace(); tok != ENDLOOP {
+			return p.unexpected(tok, lit, "'endloop'")
 		}
 
-		// Next we should see the "FROM" keyword.
-		if tok, lit := p.scanIgnoreWhitespace(); tok != ENDSOLID {
-			return nil, fmt.Errorf("found %q, expected 'endsolid'", lit)
+		if tok, lit := p.scanIgnoreWhitespace(); tok != ENDFACET {
+			return p.unexpected(tok, lit, "'endfacet'")
 		}
 
-		// The very end is the solid name but we can ignore that.
+		if err := fn(*facet); err != nil {
+			return err
+		}
+		m.Length++
+	}
 
-		// Make sure the model length reflects the facets found.
-		m.Length = int32(len(m.Facets))
+	// Next we should see the "endsolid" keyword.
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ENDSOLID {
+		return p.unexpected(tok, lit, "'endsolid'")
+	}
 
-		// Return the successfully parsed model.
-		return m, nil
+	// The very end is the solid name but we can ignore that.
+	return nil
+}
 
-	} else {
-		// Obtain the number of facets this model contains.
-		err = binary.Read(bytes.NewBuffer(data[80:84]), binary.LittleEndian, &m.Length)
+// scanVector reads the three coordinates of a vector.
+func (p *Parser) scanVector(v *geom.Vector) error {
+	for j := 0; j < 3; j++ {
+		tok, lit := p.scanIgnoreWhitespace()
+		if tok != NUMBER {
+			return p.unexpected(tok, lit, "number")
+		}
+		coordinate, err := strconv.ParseFloat(lit, 32)
 		if err != nil {
-			return m, err
+			return &SyntaxError{Location: p.location(), Found: lit, Expected: "number", Err: err}
 		}
+		v[j] = float32(coordinate)
+	}
+	return nil
+}
 
-		// Create the slice of Facets.
-		m.Facets = make([]geom.Facet, m.Length)
+// parseBinary reads the binary facet records one at a time, making sure
+// that the number of records found matches the count declared in the file.
+func (p *Parser) parseBinary(m *Model, fn FacetFunc) error {
+	if _, err := io.ReadFull(p.r, m.Header[:]); err != nil {
+		return err
+	}
 
-		// Read the slice of Facets directly from their binary reprsentation.
-		err = binary.Read(bytes.NewBuffer(data[84:]), binary.LittleEndian, &m.Facets)
-		if err != nil {
-			return m, err
+	// Obtain the number of facets this model contains.
+	if err := binary.Read(p.r, binary.LittleEndian, &m.Length); err != nil {
+		return err
+	}
+	declared := int64(uint32(m.Length))
+
+	var record [facetSize]byte
+	for i := int64(0); i < declared; i++ {
+		p.facet = int(i)
+		p.pos.Offset = binaryPrefixSize + facetSize*i
+		if n, err := io.ReadFull(p.r, record[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				p.pos.Offset += int64(n)
+				return &CountMismatchError{Location: p.location(), Declared: declared, Found: i, Extra: int64(n)}
+			}
+			return err
 		}
+		if err := fn(decodeFacet(&record)); err != nil {
+			return err
+		}
+	}
+
+	// Anything left over means the declared count is wrong.
+	p.facet = -1
+	p.pos.Offset = binaryPrefixSize + facetSize*declared
+	extra, err := io.Copy(ioutil.Discard, p.r)
+	if err != nil {
+		return err
 	}
+	if extra > 0 {
+		return &CountMismatchError{Location: p.location(), Declared: declared, Found: declared + extra/facetSize, Extra: extra % facetSize}
+	}
+
+	return nil
+}
 
-	return m, nil
+// decodeFacet decodes a binary facet record.
+func decodeFacet(record *[facetSize]byte) geom.Facet {
+	var f geom.Facet
+	vectors := [4]*geom.Vector{&f.Normal, &f.Vertex1, &f.Vertex2, &f.Vertex3}
+	for i, v := range vectors {
+		for j := 0; j < 3; j++ {
+			offset := 12*i + 4*j
+			v[j] = math.Float32frombits(binary.LittleEndian.Uint32(record[offset:]))
+		}
+	}
+	f.Attribute = binary.LittleEndian.Uint16(record[48:])
+	return f
 }
 
 // scanIgnoreWhitespace scans the next non-whitespace token.
@@ -180,17 +280,32 @@ func (p *Parser) scan() (tok Token, lit string) {
 	// If we have a token on the buffer, then return it.
 	if p.buf.n != 0 {
 		p.buf.n = 0
+		p.pos = p.buf.pos
 		return p.buf.tok, p.buf.lit
 	}
 
 	// Otherwise read the next token from the scanner.
 	tok, lit = p.s.Scan()
+	p.pos = p.s.Pos()
 
 	// Save it to the buffer in case we unscan later.
-	p.buf.tok, p.buf.lit = tok, lit
+	p.buf.tok, p.buf.lit, p.buf.pos = tok, lit, p.pos
 
 	return
 }
 
+// location returns the location of the current token.
+func (p *Parser) location() Location {
+	return Location{Pos: p.pos, Facet: p.facet}
+}
+
+// unexpected returns the error for finding tok where expected should be.
+func (p *Parser) unexpected(tok Token, lit string, expected string) error {
+	if tok == EOF {
+		return &TruncatedError{Location: p.location(), What: expected}
+	}
+	return &SyntaxError{Location: p.location(), Found: lit, Expected: expected}
+}
+
 // unscan pushes the previously read token back onto the buffer.
 func (p *Parser) unscan() { p.buf.n = 1 }
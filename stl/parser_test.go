@@ -1,7 +1,10 @@
 package stl
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/stefanom/peano/geom"
+	"io/ioutil"
 	"os"
 	"testing"
 )
@@ -37,3 +40,76 @@ func TestParsingBinary(t *testing.T) {
 		t.Error(fmt.Sprintf("Expected %v facets, got %v", 12, len(model.Facets)))
 	}
 }
+
+func TestParseFuncStreamsFacets(t *testing.T) {
+	reader, err := os.Open("test_data/cube.binary.stl")
+	if err != nil {
+		panic(err)
+	}
+	parser := NewParser(reader)
+	count := 0
+	model, err := parser.ParseFunc(func(facet geom.Facet) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 12 {
+		t.Error(fmt.Sprintf("Expected %v facets, got %v", 12, count))
+	}
+	if model.Length != 12 {
+		t.Error(fmt.Sprintf("Expected length %v, got %v", 12, model.Length))
+	}
+	if len(model.Facets) != 0 {
+		t.Error("ParseFunc should not collect facets")
+	}
+}
+
+func TestParsingTruncatedBinary(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/cube.binary.stl")
+	if err != nil {
+		panic(err)
+	}
+
+	parser := NewParser(bytes.NewReader(data[:len(data)-10]))
+	if _, err := parser.Parse(); err == nil {
+		t.Error("Expected an error parsing a truncated file")
+	}
+
+	parser = NewParser(bytes.NewReader(append(data, make([]byte, 50)...)))
+	if _, err := parser.Parse(); err == nil {
+		t.Error("Expected an error parsing a file with more facets than declared")
+	}
+}
+
+func TestRecomputeNormals(t *testing.T) {
+	data := "solid x\n" +
+		"  facet normal 0 0 0\n" +
+		"    outer loop\n" +
+		"      vertex 0 0 0\n" +
+		"      vertex 0 1 0\n" +
+		"      vertex 1 0 0\n" +
+		"    endloop\n" +
+		"  endfacet\n" +
+		"endsolid x\n"
+
+	model, err := NewParser(bytes.NewReader([]byte(data))).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.Facets[0].Normal != (geom.Vector{}) {
+		t.Errorf("Expected the normal in the file, got %v", model.Facets[0].Normal)
+	}
+
+	parser := NewParser(bytes.NewReader([]byte(data)))
+	parser.RecomputeNormals = true
+	model, err = parser.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.Facets[0].Normal != (geom.Vector{0, 0, -1}) {
+		t.Errorf("Expected a recomputed normal, got %v", model.Facets[0].Normal)
+	}
+}
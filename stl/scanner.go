@@ -3,41 +3,59 @@ package stl
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
+	"strconv"
 	"strings"
 )
 
+// Pos represents a position in the scanned input.
+type Pos struct {
+	Offset int64 // byte offset, starting at 0
+	Line   int   // line number, starting at 1
+	Column int   // column number in runes, starting at 1
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
 // Scanner represents a lexical scanner.
 type Scanner struct {
-	r *bufio.Reader
+	r   *bufio.Reader
+	pos Pos // position of the next rune to read
+	tok Pos // position of the last token scanned
+
+	// The rune read last and the position before it, to support unread.
+	last    rune
+	lastPos Pos
 }
 
 // NewScanner returns a new instance of Scanner.
 func NewScanner(r io.Reader) *Scanner {
-	return &Scanner{r: bufio.NewReader(r)}
+	return &Scanner{r: bufio.NewReader(r), pos: Pos{Line: 1, Column: 1}}
 }
 
+// Pos returns the position of the first rune of the last scanned token.
+func (s *Scanner) Pos() Pos { return s.tok }
+
 // Scan returns the next token and literal value.
 func (s *Scanner) Scan() (tok Token, lit string) {
+	s.tok = s.pos
+
 	// Read the next rune.
 	ch := s.read()
 
 	// If we see whitespace then consume all contiguous whitespace.
 	// If we see a letter then consume as an ident or reserved word.
-	// If we see a digit then consume as a number.
-
-	// NOTE: while 'e' can appear both in tokens as well as floating point
-	// numbers, it will never be the first letter in a floating point
-	// number so the order of the matching makes it work.
-	// Yeah, I know, this is somewhat hacky and an ill-defined STL file will
-	// make this barf, but I don't care right now.
+	// If we see a digit, a sign or a decimal point then consume as a number.
 	if isWhitespace(ch) {
 		s.unread()
 		return s.scanWhitespace()
 	} else if isLetter(ch) {
 		s.unread()
 		return s.scanToken()
-	} else if isNumber(ch) {
+	} else if isDigit(ch) || ch == '-' || ch == '+' || ch == '.' {
 		s.unread()
 		return s.scanNumber()
 	}
@@ -51,6 +69,27 @@ func (s *Scanner) Scan() (tok Token, lit string) {
 	return ILLEGAL, string(ch)
 }
 
+// ScanLine returns the rest of the current line, without the line ending
+// and the surrounding whitespace. It is used to read solid names, which
+// can contain anything.
+func (s *Scanner) ScanLine() string {
+	s.tok = s.pos
+
+	var buf bytes.Buffer
+	for {
+		if ch := s.read(); ch == eof {
+			break
+		} else if ch == '\n' {
+			s.unread()
+			break
+		} else {
+			buf.WriteRune(ch)
+		}
+	}
+
+	return strings.TrimSpace(buf.String())
+}
+
 // scanWhitespace consumes the current rune and all contiguous whitespace.
 func (s *Scanner) scanWhitespace() (tok Token, lit string) {
 	// Create a buffer and read the current character into it.
@@ -118,43 +157,72 @@ func (s *Scanner) scanToken() (tok Token, lit string) {
 	return ILLEGAL, buf.String()
 }
 
+// scanNumber consumes a floating point number, in the full grammar accepted
+// by the C library: an optional sign, digits with an optional decimal point
+// and an optional exponent introduced by 'e' or 'E'.
 func (s *Scanner) scanNumber() (tok Token, lit string) {
 	// Create a buffer and read the current character into it.
 	var buf bytes.Buffer
-	buf.WriteRune(s.read())
+	prev := s.read()
+	buf.WriteRune(prev)
 
-	// Read every subsequent token character into the buffer.
-	// Non-digit characters and EOF will cause the loop to exit.
+	// Read every subsequent number character into the buffer. Signs are
+	// only part of the number right after the exponent marker.
 	for {
-		if ch := s.read(); ch == eof {
-			break
-		} else if !isNumber(ch) {
-			s.unread()
-			break
+		ch := s.read()
+		if isDigit(ch) || ch == '.' || ch == 'e' || ch == 'E' ||
+			((ch == '-' || ch == '+') && (prev == 'e' || prev == 'E')) {
+			buf.WriteRune(ch)
+			prev = ch
 		} else {
-			_, _ = buf.WriteRune(ch)
+			if ch != eof {
+				s.unread()
+			}
+			break
 		}
 	}
 
+	// Make sure what we collected is actually a number.
+	if _, err := strconv.ParseFloat(buf.String(), 64); err != nil {
+		return ILLEGAL, buf.String()
+	}
+
 	return NUMBER, buf.String()
 }
 
 // read reads the next rune from the bufferred reader.
 // Returns the rune(0) if an error occurs (or io.EOF is returned).
 func (s *Scanner) read() rune {
-	ch, _, err := s.r.ReadRune()
+	ch, size, err := s.r.ReadRune()
 	if err != nil {
+		s.last = eof
 		return eof
 	}
+
+	s.last, s.lastPos = ch, s.pos
+	s.pos.Offset += int64(size)
+	if ch == '\n' {
+		s.pos.Line++
+		s.pos.Column = 1
+	} else {
+		s.pos.Column++
+	}
 	return ch
 }
 
 // unread places the previously read rune back on the reader.
-func (s *Scanner) unread() { _ = s.r.UnreadRune() }
+func (s *Scanner) unread() {
+	if s.last == eof {
+		return
+	}
+	_ = s.r.UnreadRune()
+	s.pos = s.lastPos
+	s.last = eof
+}
 
-// isWhitespace returns true if the rune is a space, tab, or newline.
+// isWhitespace returns true if the rune is a space, tab, or line ending.
 func isWhitespace(ch rune) bool {
-	return ch == ' ' || ch == '\t' || ch == '\n'
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\v' || ch == '\f'
 }
 
 // isLetter returns true if the rune is a letter.
@@ -162,9 +230,9 @@ func isLetter(ch rune) bool {
 	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch == '_')
 }
 
-// isNumber returns true if the rune can be part of a floating point number.
-func isNumber(ch rune) bool {
-	return (ch >= '0' && ch <= '9') || (ch == '-') || (ch == '+') || (ch == 'e')
+// isDigit returns true if the rune is a decimal digit.
+func isDigit(ch rune) bool {
+	return ch >= '0' && ch <= '9'
 }
 
 // eof represents a marker rune for the end of the reader.
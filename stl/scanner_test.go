@@ -0,0 +1,66 @@
+package stl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScanNumbers(t *testing.T) {
+	for _, lit := range []string{"0", "-1", "+2", "1.5", "-0.25", ".5", "5.", "1e3", "-1.000000E+00", "2.5e-07", "6.02E23"} {
+		tok, got := NewScanner(strings.NewReader(lit + " ")).Scan()
+		if tok != NUMBER || got != lit {
+			t.Errorf("Expected NUMBER %q, got %v %q", lit, tok, got)
+		}
+	}
+
+	for _, lit := range []string{"1.2.3", "1e", "-", "1e+"} {
+		if tok, _ := NewScanner(strings.NewReader(lit)).Scan(); tok != ILLEGAL {
+			t.Errorf("Expected %q to be ILLEGAL, got %v", lit, tok)
+		}
+	}
+}
+
+func TestScanPositions(t *testing.T) {
+	s := NewScanner(strings.NewReader("solid x\r\n  facet normal 1.0E+00 0 0\r\n"))
+
+	expected := []struct {
+		tok  Token
+		line int
+		col  int
+	}{
+		{SOLID, 1, 1}, {WS, 1, 6}, {ILLEGAL, 1, 7}, {WS, 1, 8},
+		{FACET, 2, 3}, {WS, 2, 8}, {NORMAL, 2, 9}, {WS, 2, 15}, {NUMBER, 2, 16},
+	}
+	for _, e := range expected {
+		tok, lit := s.Scan()
+		if tok != e.tok || s.Pos().Line != e.line || s.Pos().Column != e.col {
+			t.Errorf("Expected %v at %v:%v, got %v %q at %v", e.tok, e.line, e.col, tok, lit, s.Pos())
+		}
+	}
+}
+
+func TestParsingWindowsLineEndings(t *testing.T) {
+	data := "solid Part 1 (rev. 2)\r\n" +
+		"  facet normal 0.0 0.0 -1.0\r\n" +
+		"    outer loop\r\n" +
+		"      vertex 1.5E+00 -2.5e-1 .75\r\n" +
+		"      vertex 0 0 0\r\n" +
+		"      vertex +1 0 0\r\n" +
+		"    endloop\r\n" +
+		"  endfacet\r\n" +
+		"endsolid Part 1 (rev. 2)\r\n"
+
+	model, err := NewParser(strings.NewReader(data)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.Name != "Part 1 (rev. 2)" {
+		t.Errorf("Unexpected solid name %q", model.Name)
+	}
+	if len(model.Facets) != 1 {
+		t.Fatalf("Expected %v facets, got %v", 1, len(model.Facets))
+	}
+	if v := model.Facets[0].Vertex1; v[0] != 1.5 || v[1] != -0.25 || v[2] != 0.75 {
+		t.Errorf("Unexpected vertex %v", v)
+	}
+}
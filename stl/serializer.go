@@ -2,8 +2,13 @@ package stl
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"github.com/stefanom/peano/geom"
 	"io"
+	"math"
+	"strconv"
 )
 
 // Serializer represents a way to serialize an STL model.
@@ -16,16 +21,68 @@ func NewSerializer(w io.Writer) *Serializer {
 	return &Serializer{w: bufio.NewWriter(w)}
 }
 
-func (s *Serializer) SerializeAsAscii(name string, model *Model) {
+// SerializeAsAscii writes the model as an ASCII STL solid with the given name.
+func (s *Serializer) SerializeAsAscii(name string, model *Model) error {
 	fmt.Fprintf(s.w, "solid %v\n", name)
 	for _, facet := range model.Facets {
-		fmt.Fprintf(s.w, "  facet normal %E %E %E\n", facet.Normal[0], facet.Normal[1], facet.Normal[2])
+		fmt.Fprintf(s.w, "  facet normal %v\n", formatVector(facet.Normal))
 		fmt.Fprintf(s.w, "    outer loop\n")
-		fmt.Fprintf(s.w, "      vertex %E %E %E\n", facet.Vertex1[0], facet.Vertex1[1], facet.Vertex1[2])
-		fmt.Fprintf(s.w, "      vertex %E %E %E\n", facet.Vertex2[0], facet.Vertex2[1], facet.Vertex2[2])
-		fmt.Fprintf(s.w, "      vertex %E %E %E\n", facet.Vertex3[0], facet.Vertex3[1], facet.Vertex3[2])
+		fmt.Fprintf(s.w, "      vertex %v\n", formatVector(facet.Vertex1))
+		fmt.Fprintf(s.w, "      vertex %v\n", formatVector(facet.Vertex2))
+		fmt.Fprintf(s.w, "      vertex %v\n", formatVector(facet.Vertex3))
 		fmt.Fprintf(s.w, "    endloop\n")
 		fmt.Fprintf(s.w, "  endfacet\n")
 	}
 	fmt.Fprintf(s.w, "endsolid %v\n", name)
+
+	// The bufio.Writer remembers the first error, so flushing reports it.
+	return s.w.Flush()
+}
+
+// binaryHeader is the header of binary STL files written for models
+// whose own header would pass for ASCII.
+var binaryHeader = [headerSize]byte{'p', 'e', 'a', 'n', 'o'}
+
+// SerializeAsBinary writes the model as a binary STL, using the model
+// header and the attribute word of each facet. Models parsed from ASCII,
+// or with headers starting with "solid", get a neutral header instead, so
+// that readers sniffing the header don't take the output for ASCII.
+func (s *Serializer) SerializeAsBinary(model *Model) error {
+	header := model.Header
+	trimmed := bytes.TrimLeft(header[:], " \t\r\n")
+	if model.Format == AsciiFormat || len(trimmed) >= 5 && bytes.EqualFold(trimmed[:5], []byte("solid")) {
+		header = binaryHeader
+	}
+	s.w.Write(header[:])
+	binary.Write(s.w, binary.LittleEndian, uint32(len(model.Facets)))
+
+	var record [facetSize]byte
+	for i := range model.Facets {
+		encodeFacet(&model.Facets[i], &record)
+		s.w.Write(record[:])
+	}
+
+	// The bufio.Writer remembers the first error, so flushing reports it.
+	return s.w.Flush()
+}
+
+// formatVector formats the coordinates of a vector in exponential notation
+// with as many digits as needed to read back the exact same float32 values.
+func formatVector(v geom.Vector) string {
+	return fmt.Sprintf("%s %s %s",
+		strconv.FormatFloat(float64(v[0]), 'E', -1, 32),
+		strconv.FormatFloat(float64(v[1]), 'E', -1, 32),
+		strconv.FormatFloat(float64(v[2]), 'E', -1, 32))
+}
+
+// encodeFacet encodes a facet into a binary facet record.
+func encodeFacet(f *geom.Facet, record *[facetSize]byte) {
+	vectors := [4]*geom.Vector{&f.Normal, &f.Vertex1, &f.Vertex2, &f.Vertex3}
+	for i, v := range vectors {
+		for j := 0; j < 3; j++ {
+			offset := 12*i + 4*j
+			binary.LittleEndian.PutUint32(record[offset:], math.Float32bits(v[j]))
+		}
+	}
+	binary.LittleEndian.PutUint16(record[48:], f.Attribute)
 }
@@ -0,0 +1,141 @@
+package stl
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func parseFile(t *testing.T, filename string) *Model {
+	reader, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	model, err := NewParser(reader).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model
+}
+
+func TestBinaryIsByteForByte(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/cube.binary.stl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := parseFile(t, "test_data/cube.binary.stl")
+
+	var buf bytes.Buffer
+	if err := NewSerializer(&buf).SerializeAsBinary(model); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("Serialized binary differs from the original file")
+	}
+}
+
+func TestAsciiBinaryAsciiRoundTrip(t *testing.T) {
+	model := parseFile(t, "test_data/cube.ascii.stl")
+	model.Facets[0].Attribute = 0x1234
+
+	var ascii bytes.Buffer
+	if err := NewSerializer(&ascii).SerializeAsAscii("cube", model); err != nil {
+		t.Fatal(err)
+	}
+
+	var bin bytes.Buffer
+	if err := NewSerializer(&bin).SerializeAsBinary(model); err != nil {
+		t.Fatal(err)
+	}
+	if bin.Len() != binaryPrefixSize+facetSize*len(model.Facets) {
+		t.Errorf("Unexpected binary size %v", bin.Len())
+	}
+
+	if bytes.HasPrefix(bin.Bytes(), []byte("solid")) {
+		t.Errorf("Expected a neutral binary header, got %q", bin.Bytes()[:headerSize])
+	}
+
+	// Without the size to check against, the format is told from the data.
+	decoded, err := NewParser(&unsized{&bin}).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Format != BinaryFormat {
+		t.Errorf("Expected %v format, got %v", BinaryFormat, decoded.Format)
+	}
+	if len(decoded.Facets) != len(model.Facets) {
+		t.Fatalf("Expected %v facets, got %v", len(model.Facets), len(decoded.Facets))
+	}
+	for i := range model.Facets {
+		if decoded.Facets[i] != model.Facets[i] {
+			t.Errorf("Facet %v: expected %v, got %v", i, model.Facets[i], decoded.Facets[i])
+		}
+	}
+
+	var again bytes.Buffer
+	if err := NewSerializer(&again).SerializeAsAscii("cube", decoded); err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != ascii.String() {
+		t.Error("ASCII output changed after a binary round trip")
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestSerializerReportsErrors(t *testing.T) {
+	model := parseFile(t, "test_data/cube.ascii.stl")
+
+	if err := NewSerializer(failingWriter{}).SerializeAsAscii("cube", model); err == nil {
+		t.Error("Expected an error from SerializeAsAscii")
+	}
+	if err := NewSerializer(failingWriter{}).SerializeAsBinary(model); err == nil {
+		t.Error("Expected an error from SerializeAsBinary")
+	}
+}
+
+func TestBinaryAsciiBinaryRoundTrip(t *testing.T) {
+	model := parseFile(t, "test_data/cube.binary.stl")
+
+	var ascii bytes.Buffer
+	if err := NewSerializer(&ascii).SerializeAsAscii("cube", model); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := NewParser(&ascii).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Name != "cube" {
+		t.Errorf("Unexpected solid name %q", decoded.Name)
+	}
+	if len(decoded.Facets) != len(model.Facets) {
+		t.Fatalf("Expected %v facets, got %v", len(model.Facets), len(decoded.Facets))
+	}
+	for i := range model.Facets {
+		if decoded.Facets[i] != model.Facets[i] {
+			t.Errorf("Facet %v: expected %v, got %v", i, model.Facets[i], decoded.Facets[i])
+		}
+	}
+}
+
+func TestBinaryReplacesSolidHeader(t *testing.T) {
+	model, err := NewParser(bytes.NewReader(solidHeader(t))).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bin bytes.Buffer
+	if err := NewSerializer(&bin).SerializeAsBinary(model); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bin.Bytes()[:headerSize], binaryHeader[:]) {
+		t.Errorf("Expected a neutral binary header, got %q", bin.Bytes()[:headerSize])
+	}
+}
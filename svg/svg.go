@@ -0,0 +1,172 @@
+// Package svg draws the layers of a sliced model as SVG images, in
+// millimeters.
+package svg
+
+import (
+	"fmt"
+	"github.com/stefanom/peano/geom"
+	"github.com/stefanom/peano/slicer"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Layer is what is drawn of a layer: its islands, filled, and optionally
+// the toolpaths printing it over them.
+type Layer struct {
+	*geom.Layer
+	Perimeters []geom.Path
+	Solid      []geom.Path
+	Sparse     []geom.Path
+}
+
+// FromToolpaths returns the layer drawn with its toolpaths.
+func FromToolpaths(t *slicer.Toolpaths) Layer {
+	return Layer{Layer: t.Layer, Perimeters: t.Perimeters, Solid: t.Solid, Sparse: t.Sparse}
+}
+
+// Options control how layers are drawn. Colors are anything SVG accepts.
+type Options struct {
+	// Margin is the space left around the model, in millimeters.
+	Margin float64
+	// Fill is the color of the islands.
+	Fill string
+	// Perimeter, Solid and Sparse are the colors of the toolpaths.
+	Perimeter string
+	Solid     string
+	Sparse    string
+	// LineWidth is the width the toolpaths are drawn with.
+	LineWidth float64
+}
+
+// DefaultOptions draws gray islands with red perimeters, blue solid
+// infill and green sparse infill.
+var DefaultOptions = Options{
+	Margin:    1,
+	Fill:      "#cccccc",
+	Perimeter: "#d62728",
+	Solid:     "#1f77b4",
+	Sparse:    "#2ca02c",
+	LineWidth: 0.1,
+}
+
+// Bounds returns the bounds of the islands of all the layers.
+func Bounds(layers []Layer) geom.Bounds {
+	b := (&geom.Layer{}).Bounds()
+	for _, l := range layers {
+		b = b.Union(l.Bounds())
+	}
+	return b
+}
+
+// WriteLayer writes an image of a single layer, showing the given bounds
+// so that all the layers of a model can be drawn the same way.
+func WriteLayer(w io.Writer, layer Layer, bounds geom.Bounds, options *Options) error {
+	d := &document{w: w, options: options}
+	d.header(bounds)
+	d.layer(layer, "")
+	d.printf("</svg>\n")
+	return d.err
+}
+
+// WriteLayers writes an image of all the layers, each in its own group
+// carrying its index and height.
+func WriteLayers(w io.Writer, layers []Layer, options *Options) error {
+	d := &document{w: w, options: options}
+	d.header(Bounds(layers))
+	for _, l := range layers {
+		d.layer(l, "  ")
+	}
+	d.printf("</svg>\n")
+	return d.err
+}
+
+// document writes an SVG document, keeping the first error.
+type document struct {
+	w       io.Writer
+	options *Options
+	err     error
+}
+
+func (d *document) printf(format string, args ...interface{}) {
+	if d.err == nil {
+		_, d.err = fmt.Fprintf(d.w, format, args...)
+	}
+}
+
+// header opens the document. The Y axis of SVG goes down, so the model is
+// drawn upside down: Y coordinates are negated.
+func (d *document) header(bounds geom.Bounds) {
+	m := d.options.Margin
+	x, y := float64(bounds.Min[0])-m, -float64(bounds.Max[1])-m
+	width := float64(bounds.Max[0]-bounds.Min[0]) + 2*m
+	height := float64(bounds.Max[1]-bounds.Min[1]) + 2*m
+	if bounds.Empty() {
+		x, y, width, height = 0, 0, 0, 0
+	}
+
+	d.printf("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	d.printf("<svg xmlns=\"http://www.w3.org/2000/svg\" xmlns:inkscape=\"http://www.inkscape.org/namespaces/inkscape\" width=\"%smm\" height=\"%smm\" viewBox=\"%s %s %s %s\">\n",
+		number(width), number(height), number(x), number(y), number(width), number(height))
+}
+
+// layer draws a layer in a group, its islands first and its toolpaths over
+// them.
+func (d *document) layer(l Layer, indent string) {
+	o := d.options
+	inner := "  "
+	if indent != "" {
+		d.printf("%s<g id=\"layer-%d\" inkscape:groupmode=\"layer\" inkscape:label=\"layer %d\" data-index=\"%d\" data-z=\"%s\">\n",
+			indent, l.Index, l.Index, l.Index, number(l.Z))
+		inner = indent + "  "
+	}
+
+	for i := range l.Islands {
+		d.printf("%s<path d=\"%s\" fill=\"%s\" fill-rule=\"evenodd\"/>\n", inner, pathData(l.Islands[i].Paths(), true), o.Fill)
+	}
+	for _, overlay := range []struct {
+		paths []geom.Path
+		color string
+	}{{l.Perimeters, o.Perimeter}, {l.Solid, o.Solid}, {l.Sparse, o.Sparse}} {
+		if len(overlay.paths) > 0 {
+			d.printf("%s<path d=\"%s\" fill=\"none\" stroke=\"%s\" stroke-width=\"%s\" stroke-linecap=\"round\" stroke-linejoin=\"round\"/>\n",
+				inner, pathData(overlay.paths, false), overlay.color, number(o.LineWidth))
+		}
+	}
+
+	if indent != "" {
+		d.printf("%s</g>\n", indent)
+	}
+}
+
+// pathData returns the path data drawing the paths, closing them or not.
+func pathData(paths []geom.Path, closed bool) string {
+	var b strings.Builder
+	for _, path := range paths {
+		for i, p := range path {
+			if i == 0 {
+				b.WriteString("M")
+			} else {
+				b.WriteString(" L")
+			}
+			b.WriteString(number(float64(p[0])))
+			b.WriteString(",")
+			b.WriteString(number(-float64(p[1])))
+		}
+		if closed && len(path) > 0 {
+			b.WriteString(" Z")
+		}
+		b.WriteString(" ")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// number formats a number as short as possible, to the precision of
+// float32.
+func number(v float64) string {
+	if v == 0 {
+		// no negative zero
+		return "0"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 32)
+}
@@ -0,0 +1,113 @@
+package svg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/stefanom/peano/geom"
+	"strings"
+	"testing"
+)
+
+// svgImage is the structure of the SVG written.
+type svgImage struct {
+	Width   string    `xml:"width,attr"`
+	Height  string    `xml:"height,attr"`
+	ViewBox string    `xml:"viewBox,attr"`
+	Paths   []svgPath `xml:"path"`
+	Groups  []struct {
+		ID    string    `xml:"id,attr"`
+		Z     string    `xml:"data-z,attr"`
+		Paths []svgPath `xml:"path"`
+	} `xml:"g"`
+}
+
+type svgPath struct {
+	D        string `xml:"d,attr"`
+	Fill     string `xml:"fill,attr"`
+	FillRule string `xml:"fill-rule,attr"`
+	Stroke   string `xml:"stroke,attr"`
+}
+
+func parse(t *testing.T, b []byte) *svgImage {
+	t.Helper()
+	var d svgImage
+	if err := xml.Unmarshal(b, &d); err != nil {
+		t.Fatalf("Invalid SVG: %v\n%s", err, b)
+	}
+	return &d
+}
+
+func TestWriteLayer(t *testing.T) {
+	frame := Layer{
+		Layer:      geom.NewLayer(3, 0.6, []geom.Path{{{0, 0}, {10, 0}, {10, 10}, {0, 10}}, {{2, 2}, {4, 2}, {4, 4}, {2, 4}}}),
+		Perimeters: []geom.Path{{{0.5, 0.5}, {9.5, 0.5}, {9.5, 9.5}, {0.5, 9.5}}},
+		Sparse:     []geom.Path{{{5, 1}, {5, 9}}},
+	}
+	bounds := Bounds([]Layer{frame, {Layer: geom.NewLayer(4, 0.8, []geom.Path{{{-5, 0}, {-4, 0}, {-4, 1}, {-5, 1}}})}})
+
+	var b bytes.Buffer
+	if err := WriteLayer(&b, frame, bounds, &DefaultOptions); err != nil {
+		t.Fatal(err)
+	}
+	d := parse(t, b.Bytes())
+
+	// the bounds of both layers with a margin, upside down
+	if d.ViewBox != "-6 -11 17 12" || d.Width != "17mm" || d.Height != "12mm" {
+		t.Errorf("Unexpected view box %q of %v by %v", d.ViewBox, d.Width, d.Height)
+	}
+
+	if len(d.Paths) != 3 {
+		t.Fatalf("Expected %v paths, got %+v", 3, d.Paths)
+	}
+	island := d.Paths[0]
+	if island.FillRule != "evenodd" || island.Fill != DefaultOptions.Fill || strings.Count(island.D, "Z") != 2 {
+		t.Errorf("Expected the island with its hole, got %+v", island)
+	}
+	if !strings.HasPrefix(island.D, "M0,0 L10,0 L10,-10 L0,-10 Z M") {
+		t.Errorf("Unexpected island %q", island.D)
+	}
+	if d.Paths[1].Stroke != DefaultOptions.Perimeter || d.Paths[1].Fill != "none" {
+		t.Errorf("Expected the perimeters, got %+v", d.Paths[1])
+	}
+	if d.Paths[2].D != "M5,-1 L5,-9" || d.Paths[2].Stroke != DefaultOptions.Sparse {
+		t.Errorf("Expected the sparse infill, got %+v", d.Paths[2])
+	}
+}
+
+func TestWriteLayers(t *testing.T) {
+	var layers []Layer
+	for i := int32(1); i <= 3; i++ {
+		size := float32(i)
+		layers = append(layers, Layer{Layer: geom.NewLayer(i, float64(i)*0.2, []geom.Path{{{0, 0}, {size, 0}, {size, size}, {0, size}}})})
+	}
+
+	var b bytes.Buffer
+	if err := WriteLayers(&b, layers, &DefaultOptions); err != nil {
+		t.Fatal(err)
+	}
+	d := parse(t, b.Bytes())
+
+	if d.ViewBox != "-1 -4 5 5" {
+		t.Errorf("Unexpected view box %q", d.ViewBox)
+	}
+	if len(d.Groups) != 3 {
+		t.Fatalf("Expected %v groups, got %v", 3, len(d.Groups))
+	}
+	for i, g := range d.Groups {
+		if g.ID != "layer-"+string(rune('1'+i)) || len(g.Paths) != 1 {
+			t.Errorf("Unexpected group %+v", g)
+		}
+	}
+	if d.Groups[2].Z != "0.6" {
+		t.Errorf("Expected height %v, got %v", "0.6", d.Groups[2].Z)
+	}
+
+	// nothing to draw
+	b.Reset()
+	if err := WriteLayers(&b, nil, &DefaultOptions); err != nil {
+		t.Fatal(err)
+	}
+	if d := parse(t, b.Bytes()); d.ViewBox != "0 0 0 0" {
+		t.Errorf("Unexpected view box %q", d.ViewBox)
+	}
+}